@@ -1,7 +1,14 @@
-package xutil
+package xutil_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"image/color"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/xvill/xutil"
@@ -12,3 +19,1001 @@ func Test_Wgs2bd(t *testing.T) {
 	fmt.Println(xutil.Wgs2bd(lat, lon))
 	// 121.486245,31.3838164	121.47521,31.37982
 }
+
+// planarArea 平面面积, 扣除内环
+func planarArea(g xutil.Geo) (area float64) {
+	for _, rings := range g.Coords {
+		for i, ring := range rings {
+			s := 0.0
+			for j := 1; j < len(ring); j++ {
+				s += ring[j-1][0]*ring[j][1] - ring[j][0]*ring[j-1][1]
+			}
+			if i == 0 {
+				area += math.Abs(s) / 2
+			} else {
+				area -= math.Abs(s) / 2
+			}
+		}
+	}
+	return
+}
+
+func Test_Overlay(t *testing.T) {
+	a, _ := xutil.FromWKT("POLYGON ((0 0,2 0,2 2,0 2,0 0),(0.5 0.5,1.5 0.5,1.5 1.5,0.5 1.5,0.5 0.5))")
+	b, _ := xutil.FromWKT("POLYGON ((1 1,3 1,3 3,1 3,1 1))")
+	c, _ := xutil.FromWKT("POLYGON ((5 5,6 5,6 6,5 6,5 5))")
+	a.CRS = "EPSG:4326"
+	for _, tc := range []struct {
+		name string
+		g    xutil.Geo
+		area float64
+	}{
+		{"Intersection", a.Intersection(b), 0.75},
+		{"Union", a.Union(b), 6.25},
+		{"Difference", a.Difference(b), 2.25},
+		{"SymDifference", a.SymDifference(b), 5.5},
+		{"不相交 Intersection", a.Intersection(c), 0},
+		{"不相交 Union", a.Union(c), 4},
+		{"Difference 自身", a.Difference(a), 0},
+	} {
+		if got := planarArea(tc.g); math.Abs(got-tc.area) > 1e-9 || tc.g.CRS != a.CRS {
+			t.Errorf("%s 面积 %f, 期望 %f: %s %q", tc.name, got, tc.area, tc.g.ToWKT(), tc.g.CRS)
+		}
+	}
+	if u := a.Union(c); u.Type != "MultiPolygon" {
+		t.Errorf("Union 类型 %s", u.Type)
+	}
+
+	// 3x3 网格去掉中心格合并为带洞的多边形
+	var cells []xutil.Geo
+	for x := 0; x < 3; x++ {
+		for y := 0; y < 3; y++ {
+			if x == 1 && y == 1 {
+				continue
+			}
+			g, _ := xutil.FromWKT(fmt.Sprintf("POLYGON ((%d %d,%d %d,%d %d,%d %d,%d %d))", x, y, x+1, y, x+1, y+1, x, y+1, x, y))
+			g.CRS = a.CRS
+			cells = append(cells, g)
+		}
+	}
+	all := xutil.UnionAll(cells)
+	if all.Type != "Polygon" || len(all.Coords[0]) != 2 || math.Abs(planarArea(all)-8) > 1e-9 || all.CRS != a.CRS {
+		t.Errorf("UnionAll = %s", all.ToWKT())
+	}
+}
+
+func Test_IsValid(t *testing.T) {
+	for _, tc := range []struct{ wkt, reason string }{
+		{"POLYGON ((0 0,1 0,1 1,0 1,0 0))", "Valid Geometry"},
+		{"POLYGON ((0 0,1 0,1 1,0 1))", "Ring is not closed"},
+		{"POLYGON ((0 0,1 0,0 0))", "Too few distinct points in geometry component"},
+		{"LINESTRING (0 0,0 0)", "Too few distinct points in geometry component"},
+		{"MULTILINESTRING ((0 0,1 1),(2 2,2 2,2 2))", "Too few distinct points in geometry component"},
+		{"POLYGON ((0 0,2 2,2 0,0 2,0 0))", "Self-intersection"},
+		{"POLYGON ((0 0,2 0,1 1,2 2,0 2,1 1,0 0))", "Ring Self-intersection"},
+		{"POLYGON ((0 0,4 0,4 4,0 4,0 0),(5 5,6 5,6 6,5 5))", "Hole lies outside shell"},
+		{"POLYGON ((0 0,4 0,4 4,0 4,0 0),(1 1,3 1,3 3,1 3,1 1),(1.5 1.5,2 1.5,2 2,1.5 1.5))", "Holes are nested"},
+		{"POLYGON ((0 0,4 0,4 4,0 4,0 0),(0 2,2 1,4 2,2 3,0 2))", "Interior is disconnected"},
+		{"POLYGON ((0 0,4 0,4 4,0 4,0 0),(0 2,2 1,2 3,0 2))", "Valid Geometry"},
+		{"MULTIPOLYGON (((0 0,4 0,4 4,0 4,0 0)),((1 1,2 1,2 2,1 1)))", "Nested shells"},
+		{"MULTIPOLYGON (((0 0,1 0,1 1,0 1,0 0)),((1 1,2 1,2 2,1 2,1 1)))", "Valid Geometry"},
+		{"MULTIPOLYGON (((0 0,1 0,1 1,0 1,0 0)),((1 0,2 0,2 1,1 1,1 0)))", "Self-intersection"},
+	} {
+		g, err := xutil.FromWKT(tc.wkt)
+		if err != nil {
+			t.Fatal(tc.wkt, err)
+		}
+		if ok, reason, loc := g.IsValid(); reason != tc.reason || ok != (tc.reason == "Valid Geometry") {
+			t.Errorf("%s: %v %s %v, 期望 %s", tc.wkt, ok, reason, loc, tc.reason)
+		}
+		g.CRS = "EPSG:4326"
+		if g1 := g.MakeValid(); g1.CRS != g.CRS {
+			t.Errorf("MakeValid %s: CRS %q", tc.wkt, g1.CRS)
+		} else if ok, reason, _ := g1.IsValid(); !ok {
+			t.Errorf("MakeValid %s: %s %s", tc.wkt, reason, g1.ToWKT())
+		}
+	}
+	// 不足两个不同点的线去掉
+	if g, _ := xutil.FromWKT("MULTILINESTRING ((0 0,1 1),(2 2,2 2))"); g.MakeValid().ToWKT() != "MULTILINESTRING (( 0 0, 1 1))" {
+		t.Errorf("MakeValid = %s", g.MakeValid().ToWKT())
+	}
+	g := xutil.Geo{Type: "Point", Coords: [][][][]float64{{{{math.NaN(), 1}}}}}
+	if _, reason, _ := g.IsValid(); reason != "Invalid Coordinate" {
+		t.Errorf("NaN 坐标: %s", reason)
+	}
+}
+
+func Test_KML(t *testing.T) {
+	var fc xutil.FeatureCollection
+	for i, s := range []string{
+		"POINT Z (116.1 39.2 10)",
+		"LINESTRING (116 39,116.1 39.1,116.2 39)",
+		"POLYGON (( 0 0, 4 0, 4 4, 0 4, 0 0),( 1 1, 2 1, 2 2, 1 1))",
+		"MULTIPOINT (1 1,2 2)",
+		"GEOMETRYCOLLECTION (POINT (1 1),LINESTRING (2 2,3 3))",
+	} {
+		g, err := xutil.FromWKT(s)
+		if err != nil {
+			t.Fatal(s, err)
+		}
+		f := xutil.NewFeature(g)
+		f.ID = fmt.Sprint("p", i)
+		f.Properties["name"] = "站点&A"
+		f.Properties["h"] = 12.5
+		fc.Features = append(fc.Features, f)
+	}
+	kml, err := fc.ToKML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc2, err := xutil.FromKML(kml)
+	if err != nil || len(fc2.Features) != len(fc.Features) {
+		t.Fatal(err, kml)
+	}
+	for i, f := range fc2.Features {
+		want := fc.Features[i]
+		if f.Geometry.ToWKT() != want.Geometry.ToWKT() || f.ID != want.ID || f.Properties["name"] != "站点&A" || f.Properties["h"] != "12.5" {
+			t.Errorf("%d: %v %v %s", i, f.ID, f.Properties, f.Geometry.ToWKT())
+		}
+	}
+}
+
+func Test_GPX(t *testing.T) {
+	var fc xutil.FeatureCollection
+	for _, s := range []string{
+		"POINT Z (116.4 39.9 50.5)",
+		"LINESTRING ZM (116.4 39.9 10 1714550400.5,116.41 39.91 12 1714550410)",
+		"MULTILINESTRING (( 1 1, 2 2),( 3 3, 4 4))",
+	} {
+		g, err := xutil.FromWKT(s)
+		if err != nil {
+			t.Fatal(s, err)
+		}
+		f := xutil.NewFeature(g)
+		f.Properties["name"] = "T"
+		fc.Features = append(fc.Features, f)
+	}
+	gpx, err := fc.ToGPX()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc2, err := xutil.FromGPX(gpx)
+	if err != nil || len(fc2.Features) != len(fc.Features) {
+		t.Fatal(err, gpx)
+	}
+	for i, f := range fc2.Features {
+		if f.Geometry.ToWKT() != fc.Features[i].Geometry.ToWKT() || f.Properties["name"] != "T" {
+			t.Errorf("%d: %v %s", i, f.Properties, f.Geometry.ToWKT())
+		}
+	}
+}
+
+// Test_Shapefile 写出后读回, 面按外环逆时针、内环顺时针给出
+func Test_Shapefile(t *testing.T) {
+	var fc xutil.FeatureCollection
+	for _, tc := range []struct {
+		wkt  string
+		name string
+		code float64
+	}{
+		{"MULTIPOLYGON ((( 0 0, 10 0, 10 10, 0 10, 0 0),( 2 2, 4 4, 4 2, 2 2)),(( 20 20, 30 20, 30 30, 20 20)))", "北京市朝阳区", 110105},
+		{"POLYGON (( 0 0, 5 0, 5 5, 0 0))", "区", 2},
+	} {
+		g, _ := xutil.FromWKT(tc.wkt)
+		f := xutil.NewFeature(g)
+		f.Properties["名称"] = tc.name
+		f.Properties["code"] = tc.code
+		fc.Features = append(fc.Features, f)
+	}
+	for _, enc := range []string{"", "GBK"} {
+		s := xutil.NewShapefile(fc)
+		s.Encoding = enc
+		s.Prj = xutil.PrjWKT(4490)
+		files, err := s.Files("t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		layers, err := xutil.ParseShapefile(files, "")
+		if err != nil || len(layers) != 1 {
+			t.Fatal(enc, err, layers)
+		}
+		r := layers[0]
+		if r.SRID() != 4490 || len(r.Features) != len(fc.Features) {
+			t.Fatalf("%s: SRID %d, %d 个要素", enc, r.SRID(), len(r.Features))
+		}
+		for i, f := range r.Features {
+			want := fc.Features[i]
+			if f.Geometry.ToWKT() != want.Geometry.ToWKT() || f.Geometry.CRS != "EPSG:4490" ||
+				f.Properties["名称"] != want.Properties["名称"] || f.Properties["code"] != want.Properties["code"] {
+				t.Errorf("%s %d: %v %s %s", enc, i, f.Properties, f.Geometry.CRS, f.Geometry.ToWKT())
+			}
+		}
+	}
+
+	// Z/M 线
+	g, _ := xutil.FromWKT("MULTILINESTRING ZM ((0 0 1 5,1 1 2 6),(3 3 3 3,4 4 4 4))")
+	files, err := xutil.NewShapefile(xutil.FeatureCollection{Features: []xutil.Feature{xutil.NewFeature(g)}}).Files("zm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := xutil.ParseShapefile(files, "")
+	if err != nil || layers[0].Features[0].Geometry.ToWKT() != g.ToWKT() {
+		t.Errorf("ZM: %v %v", err, layers)
+	}
+}
+
+func Test_Proj(t *testing.T) {
+	for _, p := range [][2]float64{{116.397, 39.909}, {73.5, 18.2}, {134.9, 53.4}, {2.2945, 48.8583}} {
+		lon, lat := p[0], p[1]
+		for _, width := range []int{3, 6} {
+			x, y := xutil.Cgcs2GK(lon, lat, width)
+			if lon1, lat1 := xutil.GK2Cgcs(x, y, width); math.Abs(lon1-lon) > 1e-9 || math.Abs(lat1-lat) > 1e-9 {
+				t.Errorf("GK%d %v: %f %f -> %f %f", width, p, x, y, lon1, lat1)
+			}
+		}
+		x, y, zone, north := xutil.Wgs2UTM(lon, lat)
+		if lon1, lat1 := xutil.UTM2Wgs(x, y, zone, north); math.Abs(lon1-lon) > 1e-9 || math.Abs(lat1-lat) > 1e-9 {
+			t.Errorf("UTM %v: %f %f %d -> %f %f", p, x, y, zone, lon1, lat1)
+		}
+	}
+	// 埃菲尔铁塔 UTM 31N 448252 5411933
+	if x, y, zone, north := xutil.Wgs2UTM(2.2945, 48.8582); zone != 31 || !north || math.Abs(x-448252) > 1 || math.Abs(y-5411933) > 1 {
+		t.Errorf("Wgs2UTM = %f %f %d %v", x, y, zone, north)
+	}
+	// 中央经线上东坐标为500000, 带号前缀 20(6度带 117E)
+	if x, _ := xutil.Cgcs2GK(117, 39, 6); math.Abs(x-20500000) > 1e-6 {
+		t.Errorf("Cgcs2GK = %f", x)
+	}
+}
+
+// Test_Exact 中国范围网格上 WGS->GCJ->WGS WGS->BD->WGS 往返误差
+func Test_Exact(t *testing.T) {
+	var maxGcj, maxBd, maxApprox float64
+	for lon := 73.0; lon <= 135; lon += 0.5 {
+		for lat := 18.0; lat <= 53.5; lat += 0.5 {
+			x, y := xutil.Wgs2gcj(lon, lat)
+			x1, y1 := xutil.Gcj2WgsExact(x, y, 0)
+			maxGcj = math.Max(maxGcj, xutil.PointDistHaversine(lon, lat, x1, y1))
+			x1, y1 = xutil.Gcj2Wgs(x, y)
+			maxApprox = math.Max(maxApprox, xutil.PointDistHaversine(lon, lat, x1, y1))
+
+			x, y = xutil.Wgs2bd(lon, lat)
+			x1, y1 = xutil.Bd2WgsExact(x, y, 0)
+			maxBd = math.Max(maxBd, xutil.PointDistHaversine(lon, lat, x1, y1))
+		}
+	}
+	t.Logf("最大往返误差(米) Gcj2WgsExact:%.6f Bd2WgsExact:%.6f Gcj2Wgs:%.3f", maxGcj, maxBd, maxApprox)
+	// 正算结果保留8位小数(约1毫米)
+	if maxGcj > 0.01 || maxBd > 0.01 {
+		t.Errorf("往返误差过大 Gcj2WgsExact:%f Bd2WgsExact:%f", maxGcj, maxBd)
+	}
+}
+
+func Benchmark_Gcj2WgsExact(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		xutil.Gcj2WgsExact(121.5012091398, 31.2355502882, 0)
+	}
+}
+
+func Benchmark_Bd2WgsExact(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		xutil.Bd2WgsExact(121.5012091398, 31.2355502882, 0)
+	}
+}
+
+// Test_Geodesic Vincenty 算例 Flinders Peak -> Buninyong, 及北京 -> 纽约正反算往返
+func Test_Geodesic(t *testing.T) {
+	lon1, lat1 := 144+25/60.0+29.5244/3600, -(37 + 57/60.0 + 3.7203/3600)
+	lon2, lat2 := 143+55/60.0+35.3839/3600, -(37 + 39/60.0 + 10.1561/3600)
+	dist, azi1, azi2 := xutil.EllipsoidCGCS2000.Inverse(lon1, lat1, lon2, lat2)
+	if math.Abs(dist-54972.271) > 0.001 || math.Abs(azi1-(306+52/60.0+5.37/3600)) > 1e-5 || math.Abs(azi2-(307+10/60.0+25.07/3600)) > 1e-5 {
+		t.Errorf("Inverse = %f %f %f", dist, azi1, azi2)
+	}
+
+	dist, azi1, _ = xutil.GeodesicInverse(116.4, 39.9, -74.0, 40.7)
+	lon, lat, _ := xutil.GeodesicDirect(116.4, 39.9, dist, azi1)
+	if xutil.PointDistHaversine(lon, lat, -74.0, 40.7) > 0.001 {
+		t.Errorf("Direct = %f %f", lon, lat)
+	}
+	g, err := xutil.GeodesicInterpolate(116.4, 39.9, 117.4, 39.9, 10000)
+	if n := len(g.Coords[0][0]); err != nil || n != 10 {
+		t.Errorf("GeodesicInterpolate 点数 %d: %s %v", n, g.ToWKT(), err)
+	}
+	// 近对跖点反算不收敛
+	if g, err := xutil.GeodesicInterpolate(0, 0, 179.7, 0.1, 1e6); err == nil {
+		t.Errorf("近对跖点 GeodesicInterpolate 未返回错误: %s", g.ToWKT())
+	}
+}
+
+func Test_DistanceTo(t *testing.T) {
+	line, _ := xutil.FromWKT("LINESTRING(116 39, 117 39, 117 40)")
+	q, seg := line.NearestPoint(xutil.Point{X: 117.5, Y: 39.5})
+	if seg != 1 || math.Abs(q.X-117) > 1e-9 || math.Abs(line.DistanceTo(xutil.Point{X: 117.5, Y: 39.5})-xutil.PointDistHaversine(117.5, 39.5, q.X, q.Y)) > 1e-6 {
+		t.Errorf("NearestPoint = %v %d", q, seg)
+	}
+	// 大圆弧向北凸出, 垂足纬度略大于39
+	p := xutil.Point{X: 116.5, Y: 38.9}
+	if d, xt := line.DistanceTo(p), xutil.CrossTrackDistance(p.X, p.Y, 116, 39, 117, 39); math.Abs(d-xt) > 1e-3 {
+		t.Errorf("DistanceTo = %f, CrossTrackDistance = %f", d, xt)
+	}
+
+	poly, _ := xutil.FromWKT("POLYGON((0 0, 10 0, 10 10, 0 10, 0 0),(4 4, 6 4, 6 6, 4 6, 4 4))")
+	if d := poly.DistanceTo(xutil.Point{X: 2, Y: 2}); d != 0 {
+		t.Errorf("面内 DistanceTo = %f", d)
+	}
+	if _, seg := poly.NearestPoint(xutil.Point{X: 5, Y: 4.5}); seg != 4 {
+		t.Errorf("内环 NearestPoint 线段 %d", seg)
+	}
+	a, _ := xutil.FromWKT("LINESTRING(0 0, 0 1)")
+	b, _ := xutil.FromWKT("LINESTRING(0.01 0.5, 1 0.5)")
+	if d := a.DistanceToGeo(b); math.Abs(d-xutil.PointDistHaversine(0, 0.5, 0.01, 0.5)) > 1e-3 || poly.DistanceToGeo(b) != 0 {
+		t.Errorf("DistanceToGeo = %f", d)
+	}
+}
+
+func Test_Simplify(t *testing.T) {
+	var pts []string
+	for i := 0; i <= 100; i++ {
+		pts = append(pts, fmt.Sprintf("%g %g", 121+float64(i)*0.001, 31+0.00001*math.Sin(float64(i))))
+	}
+	gc, err := xutil.FromWKT("GEOMETRYCOLLECTION(POINT(121 31),LINESTRING(" + strings.Join(pts, ",") + "))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gc.CRS = xutil.CRSWGS84
+	for _, s := range []xutil.Geo{gc.Simplify(5), gc.SimplifyVW(500)} {
+		if n := len(s.Geoms[1].Coords[0][0]); n >= 101 || s.CRS != gc.CRS {
+			t.Errorf("简化后 %d 个点, CRS %q: %s", n, s.CRS, s.ToWKT())
+		}
+	}
+	if n := len(gc.Geoms[1].Coords[0][0]); n != 101 {
+		t.Errorf("原对象被修改: %d 个点", n)
+	}
+}
+
+func Test_WKB(t *testing.T) {
+	for _, s := range []string{
+		"POINT (1 2)",
+		"LINESTRING (3 4,10 50,20 25)",
+		"POLYGON (( 35 10, 45 45, 15 40, 10 20, 35 10),( 20 30, 35 35, 30 20, 20 30))",
+		"MULTIPOINT (10 40,40 30)",
+		"MULTILINESTRING ((10 10,20 20,10 40),(40 40,30 30,40 20))",
+		"MULTIPOLYGON ((( 40 40, 20 45, 45 30, 40 40)),(( 20 35, 10 30, 10 10, 20 35)))",
+		"GEOMETRYCOLLECTION (POINT (1 2),LINESTRING (1 2,3 4))",
+	} {
+		g, err := xutil.FromWKT(s)
+		if err != nil {
+			t.Fatal(s, err)
+		}
+		h, err := g.WKBHex()
+		if g1, err1 := xutil.FromWKBHex(h); err != nil || err1 != nil || g1.ToWKT() != g.ToWKT() {
+			t.Errorf("WKB %s: %v %v %s", s, err, err1, g1.ToWKT())
+		}
+		h, err = g.EWKBHex(4326)
+		if g1, srid, err1 := xutil.FromEWKBHex(h); err != nil || err1 != nil || srid != 4326 || g1.ToWKT() != g.ToWKT() {
+			t.Errorf("EWKB %s: %v %v %d %s", s, err, err1, srid, g1.ToWKT())
+		}
+	}
+	// PostGIS: SELECT ST_AsEWKB('SRID=4326;POINT(1 2)')
+	g, _ := xutil.FromWKT("POINT (1 2)")
+	if h, _ := g.EWKBHex(4326); h != "0101000020E6100000000000000000F03F0000000000000040" {
+		t.Errorf("EWKBHex = %s", h)
+	}
+}
+
+func Test_WKBZM(t *testing.T) {
+	for _, s := range []string{
+		"POINT Z (1 2 3)",
+		"POINT M (1 2 3)",
+		"LINESTRING ZM (1 2 3 4,5 6 7 8)",
+		"MULTIPOINT Z (1 2 3,4 5 6)",
+		"GEOMETRYCOLLECTION (POINT M (1 2 3),LINESTRING Z (1 2 3,4 5 6))",
+	} {
+		g, err := xutil.FromWKT(s)
+		if err != nil || g.ToWKT() != s {
+			t.Fatal(s, err, g.ToWKT())
+		}
+		h, err := g.EWKBHex(4490)
+		if g1, srid, err1 := xutil.FromEWKBHex(h); err != nil || err1 != nil || srid != 4490 || g1.ToWKT() != s {
+			t.Errorf("EWKB %s: %v %v %d %s", s, err, err1, srid, g1.ToWKT())
+		}
+	}
+	// PostGIS: ST_AsEWKB('SRID=4326;POINT(1 2 3)'), ST_AsBinary('POINT Z(1 2 3)')
+	g, _ := xutil.FromWKT("POINT Z (1 2 3)")
+	if h, _ := g.EWKBHex(4326); h != "01010000A0E6100000000000000000F03F00000000000000400000000000000840" {
+		t.Errorf("EWKBHex = %s", h)
+	}
+	if h, _ := g.WKBHex(); h != "01E9030000000000000000F03F00000000000000400000000000000840" {
+		t.Errorf("WKBHex = %s", h)
+	}
+}
+
+// Test_RTreeNearest 对角线的边界框包含查询点, 但线本身比小方块远
+func Test_RTreeNearest(t *testing.T) {
+	diag, _ := xutil.FromWKT("LINESTRING (121 31,122 32)")
+	square, _ := xutil.FromWKT("POLYGON ((121.21 31.8,121.22 31.8,121.22 31.81,121.21 31.81,121.21 31.8))")
+	tr := xutil.NewRTree(0)
+	tr.Insert("diag", diag)
+	tr.Insert("square", square)
+	p := xutil.Point{X: 121.2, Y: 31.8}
+	items, dists := tr.Nearest(p, 2)
+	if len(items) != 2 || items[0].ID != "square" || math.Abs(dists[1]-diag.DistanceTo(p)) > 1e-6 {
+		t.Errorf("Nearest = %v %v", items, dists)
+	}
+
+	// 高纬度点在边界框西侧: 到经线的最近点在点所在纬度以北, 按纬线取的距离不是下界
+	p = xutil.Point{X: 0, Y: 60}
+	meridian, _ := xutil.FromWKT("LINESTRING (10 50,10 70)")
+	south := xutil.Geo{Type: "Point", Coords: [][][][]float64{{{{0, 60 - 554700/111195.0}}}}}
+	if meridian.DistanceTo(p) >= south.DistanceTo(p) {
+		t.Fatalf("%f %f", meridian.DistanceTo(p), south.DistanceTo(p))
+	}
+	tr = xutil.NewRTree(0)
+	tr.Insert("south", south)
+	tr.Insert("meridian", meridian)
+	if items, dists = tr.Nearest(p, 1); len(items) != 1 || items[0].ID != "meridian" {
+		t.Errorf("Nearest = %v %v", items, dists)
+	}
+
+	// 与逐个计算距离的结果一致
+	r := rand.New(rand.NewSource(1))
+	var gs []xutil.Geo
+	tr = xutil.NewRTree(4)
+	for i := 0; i < 300; i++ {
+		x, y := r.Float64()*40+100, r.Float64()*60+10
+		g, _ := xutil.FromWKT(fmt.Sprintf("LINESTRING (%f %f,%f %f)", x, y, x+r.Float64()*3, y+r.Float64()*3))
+		gs = append(gs, g)
+		tr.Insert(i, g)
+	}
+	for n := 0; n < 50; n++ {
+		p := xutil.Point{X: r.Float64()*60 + 90, Y: r.Float64()*80 + 0}
+		ds := make([]float64, len(gs))
+		for i, g := range gs {
+			ds[i] = g.DistanceTo(p)
+		}
+		sort.Float64s(ds)
+		_, dists := tr.Nearest(p, 5)
+		for i, d := range dists {
+			if math.Abs(d-ds[i]) > 1e-6 {
+				t.Fatalf("%v: Nearest 第%d个距离 %f, 期望 %f", p, i, d, ds[i])
+			}
+		}
+	}
+}
+
+func Test_BmapMocator(t *testing.T) {
+	s := "4|13534914.0122,3645387.5227;13535422.4951,3645834.93158|1-13534914.0122,3645542.22157,13534919.2537,3645555.85957,13535111.9804,3645672.21552,13535163.1497,3645713.32995;"
+	wkt := xutil.MapAPI{}.BmapMocator(s)
+	g, err := xutil.FromBmapGeo(s)
+	if err != nil || !strings.HasPrefix(wkt, "LINESTRING (") || len(g.Points()) != 4 {
+		t.Errorf("BmapMocator = %s, FromBmapGeo = %s %v", wkt, g.ToWKT(), err)
+	}
+}
+
+func Test_Grid(t *testing.T) {
+	ext, _ := xutil.FromWKT("POLYGON ((116.3 39.8,116.32 39.8,116.31 39.82,116.3 39.8))")
+	for _, size := range []float64{0, -50, math.NaN(), math.Inf(1)} {
+		if _, err := xutil.NewSquareGrid(ext, size); err == nil {
+			t.Errorf("NewSquareGrid(%g) 未返回错误", size)
+		}
+		if _, err := xutil.NewHexGrid(ext, size); err == nil {
+			t.Errorf("NewHexGrid(%g) 未返回错误", size)
+		}
+	}
+	gr, err := xutil.NewHexGrid(ext, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	col, row := gr.Locate(116.31, 39.81)
+	if !gr.Cell(col, row).Geo.Covers(xutil.Point{X: 116.31, Y: 39.81}) {
+		t.Errorf("Locate = %d %d", col, row)
+	}
+}
+
+func Test_MVT(t *testing.T) {
+	x, y := xutil.Wgs2Tile(116.397, 39.909, 12)
+	b := xutil.TileBounds(x, y, 12).Box()
+	cx, cy, w := (b[0]+b[2])/2, (b[1]+b[3])/2, b[2]-b[0]
+	poly := xutil.Geo{Type: "Polygon", Coords: [][][][]float64{{{{cx, cy}, {cx + w/4, cy}, {cx + w/4, cy + w/4}, {cx, cy + w/4}, {cx, cy}}}}}
+	line := xutil.Geo{Type: "LineString", Coords: [][][][]float64{{{{cx - w/4, cy}, {cx - w/8, cy - w/8}}}}}
+	f1 := xutil.NewFeature(poly)
+	f1.ID = 7
+	f1.Properties["name"] = "a"
+	f2 := xutil.NewFeature(xutil.Geo{Type: "GeometryCollection", Geoms: []xutil.Geo{poly, line}})
+	f2.ID = 8
+	data, err := xutil.EncodeMVT([]xutil.MVTLayer{{Name: "cells", Features: []xutil.Feature{f1, f2}}}, x, y, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls, err := xutil.DecodeMVT(data, x, y, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := ls[0].Features
+	if len(ls) != 1 || ls[0].Name != "cells" || len(fs) != 3 {
+		t.Fatalf("DecodeMVT = %+v", ls)
+	}
+	// GeometryCollection 拆分后只有第一个要素带ID
+	if fs[0].ID != uint64(7) || fs[1].ID != uint64(8) || fs[2].ID != nil || fs[0].Properties["name"] != "a" {
+		t.Errorf("ID/属性 = %v %v %v %v", fs[0].ID, fs[1].ID, fs[2].ID, fs[0].Properties)
+	}
+	if fs[0].Geometry.Type != "Polygon" || fs[2].Geometry.Type != "LineString" {
+		t.Errorf("几何类型 = %s %s", fs[0].Geometry.Type, fs[2].Geometry.Type)
+	}
+	// 取整误差小于一个瓦片像素(extent 4096)
+	got, want := fs[0].Geometry.Box(), poly.Box()
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > w/4096 {
+			t.Errorf("边界框 %v, 原边界框 %v", got, want)
+			break
+		}
+	}
+}
+
+func Test_GeometryCollection(t *testing.T) {
+	for _, s := range []string{
+		"GEOMETRYCOLLECTION (POINT (1 2),LINESTRING (1 1,2 2),POLYGON (( 0 0, 1 0, 1 1, 0 0)))",
+		"GEOMETRYCOLLECTION (POINT EMPTY,GEOMETRYCOLLECTION (POINT (1 2)),POLYGON EMPTY)",
+		"GEOMETRYCOLLECTION EMPTY",
+		"POINT EMPTY",
+		"LINESTRING EMPTY",
+		"POLYGON EMPTY",
+		"MULTIPOLYGON EMPTY",
+	} {
+		g, err := xutil.FromWKT(s)
+		if err != nil {
+			t.Fatal(s, err)
+		}
+		if g.ToWKT() != s || g.IsEmpty() != strings.HasSuffix(s, " EMPTY") {
+			t.Errorf("ToWKT = %s, IsEmpty = %v", g.ToWKT(), g.IsEmpty())
+		}
+		js, err := g.GeoJSON()
+		if err != nil {
+			t.Fatal(s, err)
+		}
+		g1, err := xutil.FromGeoJSON(js)
+		if err != nil || g1.ToWKT() != s {
+			t.Errorf("%s -> %s -> %s %v", s, js, g1.ToWKT(), err)
+		}
+	}
+}
+
+func Test_Feature(t *testing.T) {
+	var buf bytes.Buffer
+	w := xutil.NewFeatureWriter(&buf)
+	w.BBox = []float64{116, 39, 117, 40}
+	for i, s := range []string{"POINT (116.5 39.5)", "LINESTRING (116 39,117 40)"} {
+		g, _ := xutil.FromWKT(s)
+		f := xutil.NewFeature(g)
+		f.ID = []interface{}{"a", 2}[i]
+		f.BBox = g.Box()
+		f.Properties["name"] = s[:5]
+		if err := w.Write(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Write(xutil.NewFeature(xutil.Geo{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fc, err := xutil.NewFeatureReader(&buf).ReadAll()
+	if err != nil || len(fc.Features) != 3 || len(fc.BBox) != 4 || fc.BBox[2] != 117 {
+		t.Fatalf("ReadAll = %+v %v", fc, err)
+	}
+	fs := fc.Features
+	if fs[0].ID != "a" || fs[1].ID != json.Number("2") || fs[0].Properties["name"] != "POINT" ||
+		fs[1].Geometry.ToWKT() != "LINESTRING (116 39,117 40)" || fmt.Sprint(fs[1].BBox) != "[116 39 117 40]" || fs[2].Geometry.Type != "" {
+		t.Errorf("要素 = %+v", fs)
+	}
+	s, _ := fc.GeoJSON()
+	if fc1, err := xutil.FromFeatureCollectionGeoJSON(s); err != nil || len(fc1.Features) != 3 || fc1.Features[1].ID != json.Number("2") {
+		t.Errorf("FromFeatureCollectionGeoJSON = %+v %v", fc1, err)
+	}
+
+	// type 在 features 之后, 或不是 FeatureCollection / Feature
+	for s, ok := range map[string]bool{
+		`{"features":[{"type":"Feature","geometry":null,"properties":{}}],"bbox":[1,2,3,4],"type":"FeatureCollection"}`: true,
+		`{"type":"FeatureCollection"}`:                                                   true,
+		`{"type":"Feature","geometry":null,"properties":{}}`:                             false,
+		`{"features":[{"type":"Feature","geometry":null,"properties":{}}]}`:              false,
+		`{"features":[],"type":"Topology"}`:                                              false,
+		`{"type":"FeatureCollection","features":[{"type":"Point","coordinates":[1,2]}]}`: false,
+	} {
+		if _, err := xutil.NewFeatureReader(strings.NewReader(s)).ReadAll(); (err == nil) != ok {
+			t.Errorf("%s: %v", s, err)
+		}
+	}
+
+	rows := [][]string{{"id", "lon", "lat", "wkt"}, {"1", "116.1", "39.1", "POINT (1 2)"}, {"2", "116.2", "39.2", `{"type":"Point","coordinates":[3,4]}`}}
+	fc, err = xutil.RowsToFeatures(rows, "LON", "lat")
+	if err != nil || fc.Features[1].Geometry.ToWKT() != "POINT (116.2 39.2)" || fc.Features[1].Properties["wkt"] != rows[2][3] || len(fc.Features[1].Properties) != 2 {
+		t.Errorf("RowsToFeatures 经纬度 = %+v %v", fc, err)
+	}
+	fc, err = xutil.RowsToFeatures(rows, "wkt")
+	if err != nil || fc.Features[0].Geometry.ToWKT() != "POINT (1 2)" || fc.Features[1].Geometry.ToWKT() != "POINT (3 4)" || fc.Features[0].Properties["lon"] != "116.1" {
+		t.Errorf("RowsToFeatures WKT = %+v %v", fc, err)
+	}
+	if _, err = xutil.RowsToFeatures(rows, "geom"); err == nil {
+		t.Error("RowsToFeatures 列不存在未返回错误")
+	}
+}
+
+func Test_Measure(t *testing.T) {
+	cell, _ := xutil.FromWKT("POLYGON ((0 0,1 0,1 1,0 1,0 0))")
+	if a := cell.Area(); math.Abs(a-1.2364e10)/1.2364e10 > 1e-4 {
+		t.Errorf("赤道1度格网面积 %e", a)
+	}
+	hole, _ := xutil.FromWKT("POLYGON ((0.25 0.25,0.75 0.25,0.75 0.75,0.25 0.75,0.25 0.25))")
+	donut, _ := xutil.FromWKT("POLYGON ((0 0,1 0,1 1,0 1,0 0),(0.25 0.25,0.25 0.75,0.75 0.75,0.75 0.25,0.25 0.25))")
+	if a, want := donut.Area(), cell.Area()-hole.Area(); math.Abs(a-want) > 1e-3*want || a > 0.76*cell.Area() {
+		t.Errorf("带洞面积 %e, 期望 %e", a, want)
+	}
+	if p, want := donut.Perimeter(), cell.Perimeter()+hole.Perimeter(); math.Abs(p-want) > 1e-6 {
+		t.Errorf("带洞周长 %f, 期望 %f", p, want)
+	}
+
+	// 赤道上1度约 111195 米
+	line, _ := xutil.FromWKT("MULTILINESTRING ((0 0,1 0),(0 0,0 1))")
+	if l := line.Length(); math.Abs(l-2*111195) > 1 {
+		t.Errorf("Length %f", l)
+	}
+	gc, _ := xutil.FromWKT("GEOMETRYCOLLECTION (LINESTRING (0 0,1 0),POLYGON ((0 0,1 0,1 1,0 1,0 0)))")
+	if l, a := gc.Length(), gc.Area(); math.Abs(l-111195) > 1 || a != cell.Area() || cell.Length() != 0 || line.Area() != 0 {
+		t.Errorf("GeometryCollection Length %f Area %e", l, a)
+	}
+}
+
+func Test_Predicate(t *testing.T) {
+	poly, _ := xutil.FromWKT("POLYGON ((0 0,10 0,10 10,0 10,0 0),(4 4,6 4,6 6,4 6,4 4))")
+	multi, _ := xutil.FromWKT("MULTIPOLYGON (((0 0,10 0,10 10,0 10,0 0),(4 4,6 4,6 6,4 6,4 4)),((20 20,30 20,30 30,20 30,20 20)))")
+	for _, tc := range []struct {
+		p                xutil.Point
+		contains, covers bool
+	}{
+		{xutil.Point{X: 2, Y: 2}, true, true},     // 内部
+		{xutil.Point{X: 5, Y: 5}, false, false},   // 洞内
+		{xutil.Point{X: 10, Y: 3}, false, true},   // 外环边界
+		{xutil.Point{X: 0, Y: 0}, false, true},    // 外环顶点
+		{xutil.Point{X: 5, Y: 4}, false, true},    // 内环边界
+		{xutil.Point{X: 12, Y: 5}, false, false},  // 外部
+		{xutil.Point{X: 25, Y: 25}, false, false}, // 在 MultiPolygon 第二个面内
+	} {
+		if poly.Contains(tc.p) != tc.contains || poly.Covers(tc.p) != tc.covers {
+			t.Errorf("Polygon %v: Contains %v Covers %v", tc.p, poly.Contains(tc.p), poly.Covers(tc.p))
+		}
+		in := tc.contains || tc.p.X == 25
+		if multi.Contains(tc.p) != in || multi.Covers(tc.p) != (in || tc.covers) {
+			t.Errorf("MultiPolygon %v: Contains %v Covers %v", tc.p, multi.Contains(tc.p), multi.Covers(tc.p))
+		}
+	}
+
+	for _, tc := range []struct {
+		a, b                        string
+		intersects, within, touches bool
+	}{
+		{"POLYGON ((10 0,20 0,20 10,10 10,10 0))", "POLYGON ((0 0,10 0,10 10,0 10,0 0))", true, false, true},    // 共边
+		{"POLYGON ((10 10,20 10,20 20,10 20,10 10))", "POLYGON ((0 0,10 0,10 10,0 10,0 0))", true, false, true}, // 共点
+		{"POLYGON ((1 1,2 1,2 2,1 2,1 1))", "POLYGON ((0 0,10 0,10 10,0 10,0 0))", true, true, false},           // 内含
+		{"POLYGON ((0 0,5 0,5 5,0 5,0 0))", "POLYGON ((0 0,10 0,10 10,0 10,0 0))", true, true, false},           // 内含且共边
+		{"POLYGON ((4.5 4.5,5.5 4.5,5.5 5.5,4.5 5.5,4.5 4.5))", poly.ToWKT(), false, false, false},              // 洞内
+		{"POLYGON ((4 4,6 4,6 6,4 6,4 4))", poly.ToWKT(), true, false, true},                                    // 填满洞
+		{"POLYGON ((5 5,15 5,15 15,5 15,5 5))", "POLYGON ((0 0,10 0,10 10,0 10,0 0))", true, false, false},      // 部分重叠
+		{"LINESTRING (10 2,10 8)", "POLYGON ((0 0,10 0,10 10,0 10,0 0))", true, false, true},                    // 线在边界上
+		{"LINESTRING (2 2,8 8)", poly.ToWKT(), true, false, false},                                              // 线穿过洞
+		{"POINT (22 22)", multi.ToWKT(), true, true, false},                                                     // MultiPolygon
+		{"POLYGON ((30 20,40 20,40 30,30 30,30 20))", multi.ToWKT(), true, false, true},                         // 与 MultiPolygon 第二个面共边
+		{"POLYGON ((50 50,60 50,60 60,50 60,50 50))", multi.ToWKT(), false, false, false},                       // 相离
+	} {
+		a, _ := xutil.FromWKT(tc.a)
+		b, _ := xutil.FromWKT(tc.b)
+		if a.Intersects(b) != tc.intersects || a.Disjoint(b) == tc.intersects || a.Within(b) != tc.within ||
+			a.Touches(b) != tc.touches || b.Touches(a) != tc.touches {
+			t.Errorf("%s / %s: Intersects %v Within %v Touches %v", tc.a, tc.b, a.Intersects(b), a.Within(b), a.Touches(b))
+		}
+	}
+}
+
+func Test_Hull(t *testing.T) {
+	u, _ := xutil.FromWKT("POLYGON ((0 0,10 0,10 10,8 10,8 2,2 2,2 10,0 10,0 0))")
+	u.CRS = "EPSG:4326"
+	c, p := u.Centroid(), u.PointOnSurface()
+	cp, pp := xutil.Point{X: c.Coords[0][0][0][0], Y: c.Coords[0][0][0][1]}, xutil.Point{X: p.Coords[0][0][0][0], Y: p.Coords[0][0][0][1]}
+	// 质心 (5, 212/52) 落在U形的缺口中
+	if math.Abs(cp.X-5) > 1e-9 || math.Abs(cp.Y-212.0/52) > 1e-9 || u.Covers(cp) {
+		t.Errorf("Centroid = %v", cp)
+	}
+	if !u.Contains(pp) || c.CRS != u.CRS || p.CRS != u.CRS {
+		t.Errorf("PointOnSurface = %v, CRS %q %q", pp, c.CRS, p.CRS)
+	}
+	// 线按长度加权
+	l, _ := xutil.FromWKT("LINESTRING (0 0,10 0,10 1)")
+	if c := l.Centroid().Coords[0][0][0]; math.Abs(c[0]-60.0/11) > 1e-9 || math.Abs(c[1]-0.5/11) > 1e-9 {
+		t.Errorf("LineString Centroid = %v", c)
+	}
+
+	for _, tc := range []struct{ wkt, hull string }{
+		{"MULTIPOINT ((0 0),(2 0),(1 1),(2 2),(0 2),(2 0))", "POLYGON (( 0 0, 2 0, 2 2, 0 2, 0 0))"},
+		{"MULTIPOINT ((0 0),(2 2),(1 1),(1 1))", "LINESTRING (0 0,2 2)"},
+		{"MULTIPOINT ((1 1),(1 1))", "POINT (1 1)"},
+		{"MULTIPOINT EMPTY", "POLYGON EMPTY"},
+	} {
+		g, _ := xutil.FromWKT(tc.wkt)
+		g.CRS = "EPSG:4326"
+		if h := g.ConvexHull(); h.ToWKT() != tc.hull || h.CRS != g.CRS {
+			t.Errorf("ConvexHull %s = %s %q", tc.wkt, h.ToWKT(), h.CRS)
+		}
+	}
+}
+
+func Test_Buffer(t *testing.T) {
+	check := func(name string, g xutil.Geo, area float64) {
+		if ok, reason, loc := g.IsValid(); !ok || g.CRS != "EPSG:4326" || math.Abs(g.Area()-area) > 0.005*area {
+			t.Errorf("%s: 面积 %f, 期望 %f, %s %v %q", name, g.Area(), area, reason, loc, g.CRS)
+		}
+	}
+	pt, _ := xutil.FromWKT("POINT Z (116.3 39.9 50)")
+	pt.CRS = "EPSG:4326"
+	check("点", pt.Buffer(500, 32), math.Pi*500*500)
+
+	line, _ := xutil.FromWKT("LINESTRING (116.3 39.9,116.32 39.9)")
+	line.CRS = "EPSG:4326"
+	check("线", line.Buffer(100, 32), line.Length()*200+math.Pi*100*100)
+
+	sq, _ := xutil.FromWKT("POLYGON ((116.3 39.9,116.31 39.9,116.31 39.91,116.3 39.91,116.3 39.9))")
+	sq.CRS = "EPSG:4326"
+	a, p := sq.Area(), sq.Perimeter()
+	check("面外扩", sq.Buffer(100, 32), a+p*100+math.Pi*100*100)
+	check("面收缩", sq.Buffer(-100, 32), a-p*100+4*100*100)
+	if g := sq.Buffer(-600, 8); !g.IsEmpty() || g.Type != "Polygon" {
+		t.Errorf("收缩为空: %s", g.ToWKT())
+	}
+	if g := line.Buffer(0, 8); !g.IsEmpty() {
+		t.Errorf("线缓冲0: %s", g.ToWKT())
+	}
+}
+
+func Test_Polyline(t *testing.T) {
+	coords := [][]float64{{-120.2, 38.5}, {-120.95, 40.7}, {-126.453, 43.252}}
+	const google = "_p~iF~ps|U_ulLnnqC_mqNvxq`@"
+	if s := xutil.EncodePolyline(coords, 5); s != google {
+		t.Errorf("EncodePolyline = %s", s)
+	}
+	cs, err := xutil.DecodePolyline(google, 0)
+	if err != nil || fmt.Sprint(cs) != fmt.Sprint(coords) {
+		t.Errorf("DecodePolyline = %v %v", cs, err)
+	}
+	// 精度6
+	coords6 := [][]float64{{116.397128, 39.916527}, {116.397128, 39.916527}, {-0.000001, -89.999999}}
+	cs, err = xutil.DecodePolyline(xutil.EncodePolyline(coords6, 6), 6)
+	if err != nil || fmt.Sprint(cs) != fmt.Sprint(coords6) {
+		t.Errorf("精度6 = %v %v", cs, err)
+	}
+	if cs, _ = xutil.DecodePolyline(google, 6); cs[0][0] != -12.02 || cs[0][1] != 3.85 {
+		t.Errorf("按精度6解码 = %v", cs)
+	}
+	for _, s := range []string{"_p~iF", "_p~iF~ps|U_", "_p~iF~ps|U\x7f", "_p~iF ~ps|U", "~~~~~~~~~~~~~~?"} {
+		if cs, err := xutil.DecodePolyline(s, 5); err == nil {
+			t.Errorf("DecodePolyline(%q) = %v 未返回错误", s, cs)
+		}
+	}
+
+	pt := func(x, y float64) xutil.Point {
+		lng, lat := xutil.MercatorToBd09(x, y)
+		return xutil.Point{X: lng, Y: lat}
+	}
+	g, err := xutil.FromBmapGeo("1|12958160.97,4825907.7;12958160.97,4825907.7|12958160.97,4825907.7;")
+	if err != nil || g.Type != "Point" || g.Points()[0] != pt(12958160.97, 4825907.7) {
+		t.Errorf("FromBmapGeo 点 = %s %v", g.ToWKT(), err)
+	}
+	// 外环和落在其中的内环
+	g, err = xutil.FromBmapGeo("4|12958000,4825000;12960000,4827000|1-12958000,4825000,12960000,4825000,12960000,4827000,12958000,4827000,12958000,4825000;" +
+		"1-12958500,4825500,12958500,4826500,12959500,4826500,12959500,4825500,12958500,4825500;")
+	if err != nil || g.Type != "Polygon" || len(g.Coords[0]) != 2 || g.Points()[0] != pt(12958000, 4825000) {
+		t.Errorf("FromBmapGeo 面 = %s %v", g.ToWKT(), err)
+	}
+	if ok, reason, _ := g.IsValid(); !ok {
+		t.Errorf("FromBmapGeo 面无效: %s", reason)
+	}
+	for _, s := range []string{"4|1,2", "2|1,2;3,4|1,2,3", "2|1,2;3,4|a,b,c,d;"} {
+		if _, err := xutil.FromBmapGeo(s); err == nil {
+			t.Errorf("FromBmapGeo(%q) 未返回错误", s)
+		}
+	}
+
+	g, err = xutil.FromAmapPolyline("116.1,39.1;116.2,39.2|116.3,39.3;116.4,39.4;")
+	if err != nil || g.ToWKT() != "MULTILINESTRING (( 116.1 39.1, 116.2 39.2),( 116.3 39.3, 116.4 39.4))" {
+		t.Errorf("FromAmapPolyline = %s %v", g.ToWKT(), err)
+	}
+	if _, err = xutil.FromAmapPolyline("116.1,39.1;116.2"); err == nil {
+		t.Error("FromAmapPolyline 未返回错误")
+	}
+}
+
+func Test_Geohash(t *testing.T) {
+	if h := xutil.GeohashEncode(-5.6, 42.6, 5); h != "ezs42" {
+		t.Errorf("GeohashEncode = %s", h)
+	}
+	g, err := xutil.GeohashDecode("ezs42")
+	if b := g.Box(); err != nil || math.Abs(b[0]+5.625) > 1e-9 || math.Abs(b[1]-42.583007812) > 1e-9 || math.Abs(b[2]+5.581054687) > 1e-9 || math.Abs(b[3]-42.626953125) > 1e-9 {
+		t.Errorf("GeohashDecode = %v %v", b, err)
+	}
+	if _, err = xutil.GeohashDecode("ezs4a"); err == nil {
+		t.Error("GeohashDecode 非法字符未返回错误")
+	}
+	ns, _ := xutil.GeohashNeighbors("ezs42")
+	if fmt.Sprint(ns) != "[ezs48 ezs49 ezs43 ezs41 ezs40 ezefp ezefr ezefx]" {
+		t.Errorf("GeohashNeighbors = %v", ns)
+	}
+	// 北极处没有北侧相邻格网, 经度跨180度
+	ns, _ = xutil.GeohashNeighbors(xutil.GeohashEncode(179.99, 89.99, 4))
+	if ns[0] != "" || ns[1] != "" || ns[7] != "" || ns[2] != xutil.GeohashEncode(-179.99, 89.99, 4) {
+		t.Errorf("GeohashNeighbors 极地 = %v", ns)
+	}
+
+	// 与格网完全重合的面: 自身和接触的8个相邻格网
+	cell, _ := xutil.GeohashDecode("wx4g0")
+	cover := xutil.GeohashCover(cell, 5)
+	ns, _ = xutil.GeohashNeighbors("wx4g0")
+	want := append(ns[:], "wx4g0")
+	sort.Strings(cover)
+	sort.Strings(want)
+	if fmt.Sprint(cover) != fmt.Sprint(want) {
+		t.Errorf("GeohashCover 格网 = %v, 期望 %v", cover, want)
+	}
+	// 斜线只覆盖经过的格网
+	line, _ := xutil.FromWKT("LINESTRING (116.31 39.91,116.49 39.99)")
+	cover = xutil.GeohashCover(line, 6)
+	b := line.Box()
+	set := map[string]bool{}
+	for _, h := range cover {
+		c, _ := xutil.GeohashDecode(h)
+		if !c.Intersects(line) {
+			t.Errorf("GeohashCover %s 与线不相交", h)
+		}
+		set[h] = true
+	}
+	for _, p := range [][2]float64{{b[0], b[1]}, {b[2], b[3]}, {116.4, 39.95}} {
+		if h := xutil.GeohashEncode(p[0], p[1], 6); !set[h] {
+			t.Errorf("GeohashCover 缺少 %s", h)
+		}
+	}
+	if bbox := (b[2] - b[0]) / 0.010986328125 * (b[3] - b[1]) / 0.0054931640625; float64(len(cover)) > bbox/2 {
+		t.Errorf("GeohashCover 斜线 %d 个格网, 边界框约 %.0f 个", len(cover), bbox)
+	}
+}
+
+func Test_Tile(t *testing.T) {
+	if q := xutil.TileQuadkey(3, 5, 3); q != "213" {
+		t.Errorf("TileQuadkey = %s", q)
+	}
+	if x, y, z, err := xutil.QuadkeyTile("213"); x != 3 || y != 5 || z != 3 || err != nil {
+		t.Errorf("QuadkeyTile = %d %d %d %v", x, y, z, err)
+	}
+	if _, _, _, err := xutil.QuadkeyTile("214"); err == nil {
+		t.Error("QuadkeyTile 非法字符未返回错误")
+	}
+	if b := xutil.TileBounds(0, 0, 0).Box(); b[0] != -180 || b[2] != 180 || math.Abs(b[3]-85.05112878) > 1e-8 || math.Abs(b[1]+85.05112878) > 1e-8 {
+		t.Errorf("TileBounds(0,0,0) = %v", b)
+	}
+
+	// TMS/腾讯 y 轴翻转
+	lon, lat, z := 116.397, 39.909, 12
+	x, y := xutil.TileXYZ.Tile(lon, lat, z)
+	n := 1<<uint(z) - 1
+	for _, s := range []xutil.TileScheme{xutil.TileTMS, xutil.TileTencent} {
+		if x1, y1 := s.Tile(lon, lat, z); x1 != x || y1 != n-y || fmt.Sprint(s.Bounds(x, n-y, z).Box()) != fmt.Sprint(xutil.TileBounds(x, y, z).Box()) {
+			t.Errorf("%d: Tile = %d %d, XYZ %d %d", s, x1, y1, x, y)
+		}
+	}
+	// 百度瓦片
+	bx, by := xutil.TileBaidu.Tile(lon, lat, z)
+	if x1, y1 := xutil.Bd09ToTile(lon, lat, z); bx != x1 || by != y1 || !xutil.TileBaidu.Bounds(bx, by, z).Covers(xutil.Point{X: lon, Y: lat}) {
+		t.Errorf("TileBaidu = %d %d", bx, by)
+	}
+
+	// 斜线只覆盖经过的瓦片, 不是边界框内的64个
+	lat0, lon0 := xutil.Tile2Wgs(x, y, z)
+	lat1, lon1 := xutil.Tile2Wgs(x+8, y+8, z)
+	w, h := (lon1-lon0)/16, (lat1-lat0)/16
+	line := xutil.Geo{Type: "LineString", Coords: [][][][]float64{{{{lon0 + w, lat0 + h}, {lon1 - w, lat1 - h}}}}}
+	cover := xutil.TileCover(line, z)
+	set := map[xutil.Tile]bool{}
+	for _, tl := range cover {
+		if !xutil.TileBounds(tl.X, tl.Y, tl.Z).Intersects(line) {
+			t.Errorf("TileCover %v 与线不相交", tl)
+		}
+		set[tl] = true
+	}
+	for i := 0; i < 8; i++ {
+		if !set[xutil.Tile{X: x + i, Y: y + i, Z: z}] {
+			t.Errorf("TileCover 缺少对角线瓦片 %d", i)
+		}
+	}
+	if len(cover) >= 32 {
+		t.Errorf("TileCover 斜线 %d 个瓦片", len(cover))
+	}
+	if p := xutil.TilePyramid(line, z-2, z); len(p) != len(xutil.TileCover(line, z-2))+len(xutil.TileCover(line, z-1))+len(cover) || p[0].Z != z-2 || p[len(p)-1].Z != z {
+		t.Errorf("TilePyramid = %v", p)
+	}
+}
+
+func Test_Render(t *testing.T) {
+	g, _ := xutil.FromWKT("POLYGON ((116.3 39.9,116.4 39.9,116.4 40,116.3 40,116.3 39.9),(116.34 39.94,116.36 39.94,116.36 39.96,116.34 39.96,116.34 39.94))")
+	m := xutil.NewStaticMap(100, 100)
+	m.Background = color.RGBA{200, 200, 200, 255}
+	m.Add(g, xutil.Style{Fill: color.RGBA{255, 0, 0, 255}})
+	img := m.Render()
+	for _, tc := range []struct {
+		x, y int
+		c    color.RGBA
+	}{
+		{35, 50, color.RGBA{255, 0, 0, 255}},     // 面内
+		{50, 50, color.RGBA{200, 200, 200, 255}}, // 洞内
+		{2, 2, color.RGBA{200, 200, 200, 255}},   // 背景
+	} {
+		if c := img.RGBAAt(tc.x, tc.y); c != tc.c {
+			t.Errorf("(%d,%d) = %v, 期望 %v", tc.x, tc.y, c, tc.c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := m.WritePNG(&buf); err != nil || !bytes.HasPrefix(buf.Bytes(), []byte("\x89PNG")) {
+		t.Errorf("WritePNG: %v", err)
+	}
+}
+
+func Test_CRS(t *testing.T) {
+	g, _ := xutil.FromWKT("POINT (116.397 39.909)")
+	g.CRS = "wgs84"
+	// WGS84 -> GCJ02 -> BD09 -> BD09MC
+	mc, err := g.Transform("bdmc")
+	x, y := xutil.Bd09ToMercator(xutil.Gcj2bd(xutil.Wgs2gcj(116.397, 39.909)))
+	if c := mc.Coords[0][0][0]; err != nil || mc.CRS != xutil.CRSBD09MC || c[0] != x || c[1] != y || g.Coords[0][0][0][0] != 116.397 {
+		t.Errorf("Transform BD09MC = %s %s %v", mc.ToWKT(), mc.CRS, err)
+	}
+	back, err := mc.Transform("EPSG:4326")
+	if c := back.Coords[0][0][0]; err != nil || back.CRS != xutil.CRSWGS84 || xutil.PointDistHaversine(c[0], c[1], 116.397, 39.909) > 0.01 {
+		t.Errorf("Transform WGS84 = %s %s %v", back.ToWKT(), back.CRS, err)
+	}
+	// CGCS2000 -> 3度带39带(带号前缀)
+	gk, err := g.Transform(xutil.GKCRS(116.397, 3, true))
+	if c := gk.Coords[0][0][0]; err != nil || gk.CRS != "EPSG:4527" || math.Abs(c[0]-39500000) > 60000 {
+		t.Errorf("Transform GK = %s %s %v", gk.ToWKT(), gk.CRS, err)
+	}
+
+	xutil.RegisterCRS("TEST:ISOLATED")
+	for _, tc := range []struct{ from, to string }{
+		{"", "WGS84"},
+		{"WGS84", "EPSG:9999"},
+		{"FOO", "WGS84"},
+		{"WGS84", "TEST:ISOLATED"},
+	} {
+		g.CRS = tc.from
+		if g1, err := g.Transform(tc.to); err == nil {
+			t.Errorf("%s -> %s 未返回错误: %s", tc.from, tc.to, g1.CRS)
+		}
+	}
+	if _, err := xutil.TransformFunc("TEST:ISOLATED", "WGS84"); err == nil || !strings.Contains(err.Error(), "没有") {
+		t.Errorf("TransformFunc 无路径: %v", err)
+	}
+
+	for _, tc := range []struct {
+		lon    float64
+		width  int
+		prefix bool
+		crs    string
+	}{
+		{117, 6, true, "EPSG:4498"}, {117, 6, false, "EPSG:4509"}, {117, 3, true, "EPSG:4527"}, {117, 3, false, "EPSG:4548"},
+		{75, 6, true, "EPSG:4491"}, {135, 3, false, "EPSG:4554"}, {10, 6, true, ""},
+	} {
+		if crs := xutil.GKCRS(tc.lon, tc.width, tc.prefix); crs != tc.crs {
+			t.Errorf("GKCRS(%g, %d, %v) = %s, 期望 %s", tc.lon, tc.width, tc.prefix, crs, tc.crs)
+		}
+	}
+	for crs, srid := range map[string]int{"wgs84": 4326, "CGCS2000": 4490, "4527": 4527, "EPSG:32650": 32650, "GCJ02": 0, "": 0} {
+		if s := (xutil.Geo{CRS: crs}).SRID(); s != srid {
+			t.Errorf("SRID(%q) = %d, 期望 %d", crs, s, srid)
+		}
+	}
+}
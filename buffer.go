@@ -0,0 +1,110 @@
+package xutil
+
+import "math"
+
+/***
+缓冲区, 经度按中心纬度缩放后在平面上计算, 距离单位为米
+	点: 圆; 线: 各线段胶囊形(两端为半圆)的并; 面: 与各边胶囊形求并, 负距离时求差
+	segments 为每1/4圆的线段数, 小于1时取8
+***/
+
+// Buffer 缓冲区, 返回二维的 Polygon 或 MultiPolygon, 坐标系与g相同, 点、线的距离不大于0时返回 POLYGON EMPTY
+func (g Geo) Buffer(distance float64, segments int) Geo {
+	if segments < 1 {
+		segments = 8
+	}
+	b := g.Box()
+	if b == nil {
+		return Geo{Type: "Polygon", CRS: g.CRS}
+	}
+	k := math.Cos(Radians((b[1] + b[3]) / 2))
+	d := distance / (Radians(1) * _earthR)
+	fwd := func(c []float64) []float64 { return []float64{c[0] * k, c[1]} }
+
+	var parts [][][][][]float64
+	g.eachPart(func(c []float64) {
+		if d > 0 {
+			parts = append(parts, [][][][]float64{{circleRing(coordPoint(fwd(c)), d, segments)}})
+		}
+	}, func(line [][]float64) {
+		if d > 0 {
+			parts = append(parts, lineBuffer(line, fwd, d, segments))
+		}
+	}, func(rings [][][]float64) {
+		poly := make([][][]float64, len(rings))
+		for i, ring := range rings {
+			poly[i] = make([][]float64, len(ring))
+			for j, c := range ring {
+				poly[i][j] = fwd(c)
+			}
+		}
+		if d == 0 {
+			parts = append(parts, [][][][]float64{poly})
+			return
+		}
+		var caps [][][][][]float64
+		for _, ring := range poly {
+			caps = append(caps, lineBuffer(ring, func(c []float64) []float64 { return c }, math.Abs(d), segments))
+		}
+		if d > 0 {
+			parts = append(parts, [][][][]float64{poly}, unionPolys(caps))
+		} else {
+			parts = append(parts, overlay([][][][]float64{poly}, unionPolys(caps), ovDifference))
+		}
+	})
+
+	polys := unionPolys(parts)
+	for _, rings := range polys {
+		for _, ring := range rings {
+			for _, c := range ring {
+				c[0] /= k
+			}
+		}
+	}
+	g1 := polysGeo(polys)
+	g1.CRS = g.CRS
+	return g1
+}
+
+// lineBuffer 线的缓冲区: 各线段胶囊形的并
+func lineBuffer(line [][]float64, fwd func(c []float64) []float64, d float64, segments int) [][][][]float64 {
+	var caps [][][][][]float64
+	for i := 1; i < len(line); i++ {
+		p, q := coordPoint(fwd(line[i-1])), coordPoint(fwd(line[i]))
+		if p != q {
+			caps = append(caps, [][][][]float64{{capsuleRing(p, q, d, segments)}})
+		}
+	}
+	if len(caps) == 0 && len(line) > 0 {
+		return [][][][]float64{{circleRing(coordPoint(fwd(line[0])), d, segments)}}
+	}
+	return unionPolys(caps)
+}
+
+// circleRing 圆, 逆时针闭合
+func circleRing(c Point, r float64, segments int) [][]float64 {
+	n := 4 * segments
+	ring := make([][]float64, 0, n+1)
+	for i := 0; i < n; i++ {
+		a := 2 * math.Pi * float64(i) / float64(n)
+		ring = append(ring, []float64{c.X + r*math.Cos(a), c.Y + r*math.Sin(a)})
+	}
+	return append(ring, ring[0])
+}
+
+// capsuleRing 线段pq的胶囊形, 逆时针闭合
+func capsuleRing(p, q Point, r float64, segments int) [][]float64 {
+	n := 2 * segments
+	theta := math.Atan2(q.Y-p.Y, q.X-p.X)
+	ring := make([][]float64, 0, 2*n+3)
+	for _, end := range []struct {
+		c     Point
+		start float64
+	}{{q, theta - math.Pi/2}, {p, theta + math.Pi/2}} {
+		for i := 0; i <= n; i++ {
+			a := end.start + math.Pi*float64(i)/float64(n)
+			ring = append(ring, []float64{end.c.X + r*math.Cos(a), end.c.Y + r*math.Sin(a)})
+		}
+	}
+	return append(ring, ring[0])
+}
@@ -0,0 +1,244 @@
+package xutil
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/***
+坐标系注册与转换
+	坐标系以名称标识: EPSG坐标系为 "EPSG:代码", 其他为 GCJ02 BD09 BD09MC; 名称不区分大小写, 纯数字视为EPSG代码
+	RegisterTransform 注册两个坐标系间的单向转换, Transform 按广度优先查找转换次数最少的路径
+	CGCS2000 与 WGS84 的差异在厘米级, 两者直接互换; GCJ02/BD09 的反算用迭代的 Exact 版本
+	Geo.Wgs2gcj Geo.Bd2Wgs 等方法只转换坐标, 不更新 CRS, 与 Transform 混用时须注意
+	内置:
+		EPSG:4326(WGS84) GCJ02 BD09 BD09MC(百度墨卡托) EPSG:3857 EPSG:4490(CGCS2000)
+		EPSG:4491-4501 CGCS2000 6度带 13-23带(带号前缀)  EPSG:4502-4512 6度带 中央经线75E-135E
+		EPSG:4513-4533 CGCS2000 3度带 25-45带(带号前缀)  EPSG:4534-4554 3度带 中央经线75E-135E
+		EPSG:32601-32660 EPSG:32701-32760 WGS84 UTM 北/南半球
+***/
+
+// 内置坐标系名称
+const (
+	CRSWGS84       = "EPSG:4326"
+	CRSGCJ02       = "GCJ02"
+	CRSBD09        = "BD09"
+	CRSBD09MC      = "BD09MC"
+	CRSWebMercator = "EPSG:3857"
+	CRSCGCS2000    = "EPSG:4490"
+)
+
+type transformFunc = func(x, y float64) (float64, float64)
+
+var (
+	_crsMu    sync.RWMutex
+	_crsAlias = map[string]string{}
+	_crsEdges = map[string]map[string]transformFunc{}
+)
+
+func init() {
+	RegisterCRS(CRSWGS84, "WGS84", "WGS-84", "WGS 84")
+	RegisterCRS(CRSGCJ02, "GCJ-02", "GCJ")
+	RegisterCRS(CRSBD09, "BD-09", "BD09LL")
+	RegisterCRS(CRSBD09MC, "BDMC")
+	RegisterCRS(CRSWebMercator, "EPSG:900913", "WebMercator")
+	RegisterCRS(CRSCGCS2000, "CGCS2000")
+
+	RegisterTransform(CRSWGS84, CRSGCJ02, Wgs2gcj)
+	RegisterTransform(CRSGCJ02, CRSWGS84, func(lon, lat float64) (float64, float64) { return Gcj2WgsExact(lon, lat, 0) })
+	RegisterTransform(CRSGCJ02, CRSBD09, Gcj2bd)
+	RegisterTransform(CRSBD09, CRSGCJ02, func(lon, lat float64) (float64, float64) { return Bd2gcjExact(lon, lat, 0) })
+	RegisterTransform(CRSBD09, CRSBD09MC, Bd09ToMercator)
+	RegisterTransform(CRSBD09MC, CRSBD09, MercatorToBd09)
+	RegisterTransform(CRSWGS84, CRSWebMercator, Wgs2WebMercator)
+	RegisterTransform(CRSWebMercator, CRSWGS84, WebMercator2Wgs)
+	same := func(x, y float64) (float64, float64) { return x, y }
+	RegisterTransform(CRSWGS84, CRSCGCS2000, same)
+	RegisterTransform(CRSCGCS2000, CRSWGS84, same)
+
+	for zone := 13; zone <= 23; zone++ {
+		registerGK(4491+zone-13, float64(zone*6-3), zone)
+		registerGK(4502+zone-13, float64(zone*6-3), 0)
+	}
+	for zone := 25; zone <= 45; zone++ {
+		registerGK(4513+zone-25, float64(zone*3), zone)
+		registerGK(4534+zone-25, float64(zone*3), 0)
+	}
+	for zone := 1; zone <= 60; zone++ {
+		registerUTM(zone, true)
+		registerUTM(zone, false)
+	}
+}
+
+// registerGK 注册CGCS2000高斯-克吕格投影, prefix 为带号前缀, 0为不带前缀
+func registerGK(srid int, cm float64, prefix int) {
+	name, off := "EPSG:"+strconv.Itoa(srid), float64(prefix)*1e6
+	RegisterTransform(CRSCGCS2000, name, func(lon, lat float64) (float64, float64) {
+		x, y := LonLat2GK(lon, lat, cm)
+		return x + off, y
+	})
+	RegisterTransform(name, CRSCGCS2000, func(x, y float64) (float64, float64) {
+		return GK2LonLat(x-off, y, cm)
+	})
+}
+
+// registerUTM 注册WGS84 UTM投影
+func registerUTM(zone int, north bool) {
+	srid := 32600 + zone
+	if !north {
+		srid += 100
+	}
+	name := "EPSG:" + strconv.Itoa(srid)
+	RegisterTransform(CRSWGS84, name, func(lon, lat float64) (float64, float64) {
+		return Wgs2UTMZone(lon, lat, zone, north)
+	})
+	RegisterTransform(name, CRSWGS84, func(x, y float64) (float64, float64) {
+		return UTM2Wgs(x, y, zone, north)
+	})
+}
+
+//===============================================================================
+
+// crsKey 名称的查找键: 大写, 纯数字加 EPSG: 前缀
+func crsKey(name string) string {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if _, err := strconv.Atoi(name); err == nil {
+		return "EPSG:" + name
+	}
+	return name
+}
+
+// RegisterCRS 注册坐标系及其别名
+func RegisterCRS(name string, aliases ...string) {
+	_crsMu.Lock()
+	defer _crsMu.Unlock()
+	registerCRS(name, aliases...)
+}
+
+func registerCRS(name string, aliases ...string) {
+	_crsAlias[crsKey(name)] = name
+	for _, a := range aliases {
+		_crsAlias[crsKey(a)] = name
+	}
+}
+
+// RegisterTransform 注册 from 到 to 的坐标转换, 未注册的坐标系自动注册
+func RegisterTransform(from, to string, f func(x, y float64) (float64, float64)) {
+	_crsMu.Lock()
+	defer _crsMu.Unlock()
+	for _, name := range []string{from, to} {
+		if _, ok := _crsAlias[crsKey(name)]; !ok {
+			registerCRS(name)
+		}
+	}
+	from, to = _crsAlias[crsKey(from)], _crsAlias[crsKey(to)]
+	if _crsEdges[from] == nil {
+		_crsEdges[from] = make(map[string]transformFunc)
+	}
+	_crsEdges[from][to] = f
+}
+
+// CRSName 坐标系的注册名称, 如 "wgs84" 返回 "EPSG:4326"
+func CRSName(name string) (string, error) {
+	_crsMu.RLock()
+	defer _crsMu.RUnlock()
+	if n, ok := _crsAlias[crsKey(name)]; ok {
+		return n, nil
+	}
+	return "", fmt.Errorf("未注册的坐标系: %s", name)
+}
+
+// TransformFunc 查找 from 到 to 转换次数最少的路径, 返回组合后的转换函数
+func TransformFunc(from, to string) (func(x, y float64) (float64, float64), error) {
+	from, err := CRSName(from)
+	if err != nil {
+		return nil, err
+	}
+	if to, err = CRSName(to); err != nil {
+		return nil, err
+	}
+
+	_crsMu.RLock()
+	defer _crsMu.RUnlock()
+	prev := map[string]string{from: ""}
+	for queue := []string{from}; len(queue) > 0 && prev[to] == "" && from != to; queue = queue[1:] {
+		// 按名称顺序遍历, 保证路径确定
+		var next []string
+		for n := range _crsEdges[queue[0]] {
+			if _, ok := prev[n]; !ok {
+				next = append(next, n)
+			}
+		}
+		sort.Strings(next)
+		for _, n := range next {
+			prev[n] = queue[0]
+			queue = append(queue, n)
+		}
+	}
+	if _, ok := prev[to]; !ok {
+		return nil, fmt.Errorf("没有 %s 到 %s 的转换", from, to)
+	}
+
+	var fs []transformFunc
+	for n := to; n != from; n = prev[n] {
+		fs = append([]transformFunc{_crsEdges[prev[n]][n]}, fs...)
+	}
+	return func(x, y float64) (float64, float64) {
+		for _, f := range fs {
+			x, y = f(x, y)
+		}
+		return x, y
+	}, nil
+}
+
+// GKCRS 经度所在的CGCS2000高斯-克吕格投影带, prefix 为东坐标是否带带号前缀, 不在中国范围内时返回空字符串
+func GKCRS(lon float64, width int, prefix bool) string {
+	zone, _ := GKZone(lon, width)
+	srid := 4502 + zone - 13
+	switch {
+	case width == 3 && (zone < 25 || zone > 45), width != 3 && (zone < 13 || zone > 23):
+		return ""
+	case width == 3 && prefix:
+		srid = 4513 + zone - 25
+	case width == 3:
+		srid = 4534 + zone - 25
+	case prefix:
+		srid = 4491 + zone - 13
+	}
+	return "EPSG:" + strconv.Itoa(srid)
+}
+
+//===============================================================================
+
+// SRID 坐标系的EPSG代码, 未设置或不是EPSG坐标系时为0
+func (g Geo) SRID() int {
+	name, err := CRSName(g.CRS)
+	if err != nil {
+		name = crsKey(g.CRS)
+	}
+	srid, _ := strconv.Atoi(strings.TrimPrefix(name, "EPSG:"))
+	return srid
+}
+
+// Transform 转换到坐标系 to, 返回设置了 CRS 的副本, 原对象须设置 CRS
+func (g Geo) Transform(to string) (Geo, error) {
+	if g.CRS == "" {
+		return g, errors.New("未设置坐标系 CRS")
+	}
+	name, err := CRSName(to)
+	if err != nil {
+		return g, err
+	}
+	f, err := TransformFunc(g.CRS, name)
+	if err != nil {
+		return g, err
+	}
+	g1 := g.Copy()
+	g1.PointFunc(f)
+	g1.CRS = name
+	return g1, nil
+}
@@ -0,0 +1,416 @@
+package xutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/***
+GeoJSON Feature / FeatureCollection
+https://datatracker.ietf.org/doc/html/rfc7946#section-3.2
+***/
+
+// Feature GeoJSON要素, Geometry.Type 为空时输出 "geometry":null
+type Feature struct {
+	ID         interface{}
+	Geometry   Geo
+	Properties map[string]interface{}
+	BBox       []float64
+}
+
+// FeatureCollection GeoJSON要素集合
+type FeatureCollection struct {
+	Features []Feature
+	BBox     []float64
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id,omitempty"`
+	BBox       []float64              `json:"bbox,omitempty"`
+	Geometry   json.RawMessage        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string            `json:"type"`
+	BBox     []float64         `json:"bbox,omitempty"`
+	Features []json.RawMessage `json:"features"`
+}
+
+// NewFeature 创建要素
+func NewFeature(g Geo) Feature {
+	return Feature{Geometry: g, Properties: make(map[string]interface{})}
+}
+
+// MarshalJSON 生成GeoJSON Feature
+func (f Feature) MarshalJSON() ([]byte, error) {
+	gf := geoJSONFeature{Type: "Feature", ID: f.ID, BBox: f.BBox, Properties: f.Properties, Geometry: json.RawMessage("null")}
+	if f.Geometry.Type != "" {
+		s, err := f.Geometry.GeoJSON()
+		if err != nil {
+			return nil, err
+		}
+		gf.Geometry = json.RawMessage(s)
+	}
+	return json.Marshal(gf)
+}
+
+// UnmarshalJSON 解析GeoJSON Feature, 数值型id保留为json.Number
+func (f *Feature) UnmarshalJSON(b []byte) error {
+	var gf geoJSONFeature
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&gf); err != nil {
+		return err
+	}
+	if gf.Type != "Feature" {
+		return fmt.Errorf("不是GeoJSON Feature: %s", gf.Type)
+	}
+	*f = Feature{ID: gf.ID, BBox: gf.BBox, Properties: gf.Properties}
+	if len(gf.Geometry) == 0 || string(gf.Geometry) == "null" {
+		return nil
+	}
+	g, err := FromGeoJSON(string(gf.Geometry))
+	f.Geometry = g
+	return err
+}
+
+// GeoJSON 生成GeoJSON Feature
+func (f Feature) GeoJSON() (string, error) {
+	b, err := json.Marshal(f)
+	return string(b), err
+}
+
+// MarshalJSON 生成GeoJSON FeatureCollection
+func (fc FeatureCollection) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	w := NewFeatureWriter(&buf)
+	w.BBox = fc.BBox
+	for _, f := range fc.Features {
+		if err := w.Write(f); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON 解析GeoJSON FeatureCollection
+func (fc *FeatureCollection) UnmarshalJSON(b []byte) error {
+	var gfc geoJSONFeatureCollection
+	if err := json.Unmarshal(b, &gfc); err != nil {
+		return err
+	}
+	if gfc.Type != "FeatureCollection" {
+		return fmt.Errorf("不是GeoJSON FeatureCollection: %s", gfc.Type)
+	}
+	*fc = FeatureCollection{BBox: gfc.BBox, Features: make([]Feature, len(gfc.Features))}
+	for i, raw := range gfc.Features {
+		if err := fc.Features[i].UnmarshalJSON(raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GeoJSON 生成GeoJSON FeatureCollection
+func (fc FeatureCollection) GeoJSON() (string, error) {
+	b, err := json.Marshal(fc)
+	return string(b), err
+}
+
+// Box 所有要素的方框边界 minx, miny, maxx, maxy
+func (fc FeatureCollection) Box() []float64 {
+	var box []float64
+	for _, f := range fc.Features {
+		b := f.Geometry.Box()
+		if b == nil {
+			continue
+		}
+		if box == nil {
+			box = b
+			continue
+		}
+		box[0], box[1] = math.Min(box[0], b[0]), math.Min(box[1], b[1])
+		box[2], box[3] = math.Max(box[2], b[2]), math.Max(box[3], b[3])
+	}
+	return box
+}
+
+// FromFeatureGeoJSON 解析GeoJSON Feature
+func FromFeatureGeoJSON(geojson string) (f Feature, err error) {
+	err = json.Unmarshal([]byte(geojson), &f)
+	return
+}
+
+// FromFeatureCollectionGeoJSON 解析GeoJSON FeatureCollection
+func FromFeatureCollectionGeoJSON(geojson string) (fc FeatureCollection, err error) {
+	err = json.Unmarshal([]byte(geojson), &fc)
+	return
+}
+
+//===============================================================================
+
+// FeatureWriter 流式写出FeatureCollection, Close 后才是完整的GeoJSON
+type FeatureWriter struct {
+	BBox []float64
+	w    io.Writer
+	n    int
+}
+
+// NewFeatureWriter 创建FeatureWriter
+func NewFeatureWriter(w io.Writer) *FeatureWriter {
+	return &FeatureWriter{w: w}
+}
+
+func (fw *FeatureWriter) header() error {
+	head := `{"type":"FeatureCollection",`
+	if fw.BBox != nil {
+		b, err := json.Marshal(fw.BBox)
+		if err != nil {
+			return err
+		}
+		head += `"bbox":` + string(b) + ","
+	}
+	_, err := io.WriteString(fw.w, head+`"features":[`)
+	return err
+}
+
+// Write 写出一个要素
+func (fw *FeatureWriter) Write(f Feature) error {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if fw.n == 0 {
+		err = fw.header()
+	} else {
+		_, err = io.WriteString(fw.w, ",")
+	}
+	if err != nil {
+		return err
+	}
+	fw.n++
+	_, err = fw.w.Write(b)
+	return err
+}
+
+// Close 写出结尾
+func (fw *FeatureWriter) Close() error {
+	if fw.n == 0 {
+		if err := fw.header(); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(fw.w, "]}")
+	return err
+}
+
+//===============================================================================
+
+// FeatureReader 流式读取FeatureCollection, 逐个返回要素
+// type 须为 FeatureCollection, 在 features 之后出现时读完要素后才检查
+type FeatureReader struct {
+	BBox []float64
+	d    *json.Decoder
+	typ  string
+	in   bool
+	done bool
+}
+
+// NewFeatureReader 创建FeatureReader
+func NewFeatureReader(r io.Reader) *FeatureReader {
+	return &FeatureReader{d: json.NewDecoder(r)}
+}
+
+// seek 定位到 features 数组内部, 跳过其他字段
+func (fr *FeatureReader) seek() error {
+	t, err := fr.d.Token()
+	if err != nil {
+		return err
+	}
+	if t != json.Delim('{') {
+		return errors.New("不是GeoJSON FeatureCollection")
+	}
+	if err = fr.fields(true); err != nil {
+		return err
+	}
+	if !fr.in {
+		return fr.end()
+	}
+	return nil
+}
+
+// fields 读取对象字段, stop 时停在 features 数组内部
+func (fr *FeatureReader) fields(stop bool) error {
+	for fr.d.More() {
+		t, err := fr.d.Token()
+		if err != nil {
+			return err
+		}
+		var raw json.RawMessage
+		switch t {
+		case "features":
+			if !stop {
+				err = fr.d.Decode(&raw)
+				break
+			}
+			if t, err = fr.d.Token(); err != nil {
+				return err
+			}
+			if t != json.Delim('[') {
+				return errors.New("features 不是数组")
+			}
+			fr.in = true
+			return nil
+		case "type":
+			if err = fr.d.Decode(&fr.typ); err == nil && fr.typ != "FeatureCollection" {
+				return fmt.Errorf("不是GeoJSON FeatureCollection: %s", fr.typ)
+			}
+		case "bbox":
+			err = fr.d.Decode(&fr.BBox)
+		default:
+			err = fr.d.Decode(&raw)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// end 读取 features 之后的字段并检查 type, 正常结束时返回 io.EOF
+func (fr *FeatureReader) end() error {
+	fr.done = true
+	if fr.in {
+		if _, err := fr.d.Token(); err != nil {
+			return err
+		}
+		if err := fr.fields(false); err != nil {
+			return err
+		}
+	}
+	if fr.typ != "FeatureCollection" {
+		return errors.New("不是GeoJSON FeatureCollection: 缺少 type")
+	}
+	return io.EOF
+}
+
+// Next 读取下一个要素, 结束时返回 io.EOF
+func (fr *FeatureReader) Next() (f Feature, err error) {
+	if fr.done {
+		return f, io.EOF
+	}
+	if !fr.in {
+		if err = fr.seek(); err != nil {
+			return
+		}
+	}
+	if !fr.d.More() {
+		return f, fr.end()
+	}
+	err = fr.d.Decode(&f)
+	return
+}
+
+// ReadAll 读取所有要素
+func (fr *FeatureReader) ReadAll() (fc FeatureCollection, err error) {
+	for {
+		f, err := fr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fc, err
+		}
+		fc.Features = append(fc.Features, f)
+	}
+	fc.BBox = fr.BBox
+	return fc, nil
+}
+
+//===============================================================================
+
+// RowsToFeatures CSV数据转要素, rows[0] 为表头
+// geoCols 为一列时该列为WKT或GeoJSON, 为两列时为经度、纬度列, 其余列作为属性
+func RowsToFeatures(rows [][]string, geoCols ...string) (fc FeatureCollection, err error) {
+	if len(rows) == 0 {
+		return fc, errors.New("empty rows")
+	}
+	head := rows[0]
+	geoInd := make([]int, len(geoCols))
+	for i, col := range geoCols {
+		if geoInd[i] = StringsIndex(StringsLower(head), strings.ToLower(col)); geoInd[i] < 0 {
+			return fc, fmt.Errorf("列不存在: %s", col)
+		}
+	}
+	if len(geoInd) != 1 && len(geoInd) != 2 {
+		return fc, errors.New("geoCols 须为 WKT列 或 经度列,纬度列")
+	}
+
+	fc.Features = make([]Feature, 0, len(rows)-1)
+	for n, row := range rows[1:] {
+		if len(row) < len(head) {
+			return fc, fmt.Errorf("第%d行列数不足", n+2)
+		}
+		var g Geo
+		if len(geoInd) == 2 {
+			var p Point
+			if p, err = NewPoint(row[geoInd[0]], row[geoInd[1]]); err == nil {
+				g = Geo{Type: "Point", Coords: [][][][]float64{{{{p.X, p.Y}}}}}
+			}
+		} else if s := strings.TrimSpace(row[geoInd[0]]); strings.HasPrefix(s, "{") {
+			g, err = FromGeoJSON(s)
+		} else if s != "" {
+			g, err = FromWKT(s)
+		}
+		if err != nil {
+			return fc, fmt.Errorf("第%d行: %v", n+2, err)
+		}
+
+		f := NewFeature(g)
+		for i, k := range head {
+			if IntsIndex(geoInd, i) < 0 {
+				f.Properties[k] = row[i]
+			}
+		}
+		fc.Features = append(fc.Features, f)
+	}
+	return fc, nil
+}
+
+// FeaturesToRows 要素转CSV数据, 几何对象以WKT输出到首列 wkt
+func FeaturesToRows(fc FeatureCollection, cols []string) (rows [][]string) {
+	rows = append(rows, append([]string{"wkt"}, cols...))
+	for _, f := range fc.Features {
+		row := make([]string, 0, len(cols)+1)
+		if f.Geometry.Type != "" {
+			row = append(row, f.Geometry.ToWKT())
+		} else {
+			row = append(row, "")
+		}
+		for _, k := range cols {
+			switch v := f.Properties[k].(type) {
+			case nil:
+				row = append(row, "")
+			case string:
+				row = append(row, v)
+			case float64:
+				row = append(row, strconv.FormatFloat(v, 'f', -1, 64))
+			default:
+				row = append(row, fmt.Sprint(v))
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
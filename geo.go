@@ -1,334 +1,754 @@
-package xutil
-
-import (
-	"bufio"
-	"bytes"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"strconv"
-	"strings"
-)
-
-type Geo struct {
-	Type   string
-	Coords [][][][]float64
-}
-
-type Point struct {
-	X float64
-	Y float64
-}
-
-func (p Point) String() string {
-	return fmt.Sprintf("%g,%g", p.X, p.Y)
-}
-
-type Line struct {
-	P1 Point
-	P2 Point
-}
-
-//===============================================================================
-
-func NewPoint(x, y string) (p Point, err error) {
-	m, err := strconv.ParseFloat(x, 64)
-	if err != nil {
-		return p, err
-	}
-	n, err := strconv.ParseFloat(y, 64)
-	if err != nil {
-		return p, err
-	}
-	return Point{X: m, Y: n}, nil
-}
-
-func (p *Point) ReverseXY() {
-	p.X, p.Y = p.Y, p.X
-}
-
-func (p *Point) Wgs2gcj() {
-	p.X, p.Y = Wgs2gcj(p.X, p.Y)
-}
-
-func (p *Point) Wgs2bd() {
-	p.X, p.Y = Wgs2bd(p.X, p.Y)
-}
-
-func (p *Point) Gcj2bd() {
-	p.X, p.Y = Gcj2bd(p.X, p.Y)
-}
-
-//===============================================================================
-
-func (g Geo) Lines() []Line {
-	lines := []Line{}
-	for _, a := range g.Coords {
-		for _, b := range a {
-			lines = append(lines, Line{Point{b[0][0], b[0][1]}, Point{b[1][0], b[1][1]}})
-		}
-	}
-	return lines
-}
-func (g Geo) Points() []Point {
-	points := []Point{}
-	for _, a := range g.Coords {
-		for _, b := range a {
-			for _, c := range b {
-				points = append(points, Point{c[0], c[1]})
-			}
-		}
-	}
-	return points
-}
-
-// Copy CopyGeo
-func (g Geo) Copy() Geo {
-	var g1 Geo
-	g1.Type = g.Type
-	g1.Coords = make([][][][]float64, len(g.Coords))
-	for i, a := range g.Coords {
-		g1.Coords[i] = make([][][]float64, len(a))
-		for j, b := range a {
-			g1.Coords[i][j] = make([][]float64, len(b))
-			for k, c := range b {
-				g1.Coords[i][j][k] = make([]float64, len(c))
-				copy(g1.Coords[i][j][k], c)
-			}
-		}
-	}
-	return g1
-}
-
-//===============================================================================
-
-// FromWKT 解析WKT为Geo
-func FromWKT(wkt string) (g Geo, err error) {
-	wkt = strings.NewReplacer("(", " [ ", ")", " ] ", ",", " , ").Replace(wkt)
-	bf := bufio.NewScanner(strings.NewReader(wkt))
-	bf.Split(bufio.ScanWords)
-
-	var retstr bytes.Buffer
-	flag := false
-	bf.Scan()
-	_type := bf.Text()
-	for bf.Scan() {
-		w := bf.Text()
-		if flag && w != "[" && w != "]" && w != "," {
-			retstr.WriteRune(',')
-			retstr.WriteString(w)
-			retstr.WriteRune(']')
-			flag = true
-		} else if w != "[" && w != "]" && w != "," {
-			retstr.WriteRune('[')
-			retstr.WriteString(w)
-			flag = true
-		} else {
-			retstr.WriteString(w)
-			flag = false
-		}
-	}
-
-	_coordinates := retstr.String()
-	if _coordinates == "[]" {
-		return g, errors.New(wkt + " empty coordinates")
-	}
-	_type = strings.NewReplacer("POINT", "Point", "LINESTRING", "LineString", "MULTILINESTRING", "MultiLineString",
-		"POLYGON", "Polygon", "MULTIPOLYGON", "MultiPolygon", "MULTIPOINT", "MultiPoint").Replace(strings.ToUpper(_type))
-
-	rawjson := fmt.Sprintf(`{"type":"%s","coordinates":%s}`, _type, _coordinates)
-	return FromGeoJSON(rawjson)
-}
-
-// FromGeoJSON 解析GeoJSON为Geo
-func FromGeoJSON(geojson string) (g Geo, err error) {
-	type GeoJSON struct {
-		Type   string          `json:"type"`
-		Coords json.RawMessage `json:"coordinates"`
-	}
-
-	var gj GeoJSON
-	err = json.Unmarshal([]byte(geojson), &gj)
-	if err != nil {
-		return g, err
-	}
-
-	g.Coords = [][][][]float64{{{{}}}}
-	g.Type = strings.NewReplacer("POINT", "Point", "LINESTRING", "LineString", "MULTILINESTRING", "MultiLineString",
-		"POLYGON", "Polygon", "MULTIPOLYGON", "MultiPolygon", "MULTIPOINT", "MultiPoint").Replace(strings.ToUpper(gj.Type))
-
-	var v4 [][][][]float64
-	err = json.Unmarshal(gj.Coords, &v4)
-	if err == nil {
-		g.Coords = v4
-		return
-	}
-
-	var v3 [][][]float64
-	err = json.Unmarshal(gj.Coords, &v3)
-	if err == nil {
-		g.Coords[0] = v3
-		return
-	}
-
-	var v2 [][]float64
-	err = json.Unmarshal(gj.Coords, &v2)
-	if err == nil {
-		g.Coords[0][0] = v2
-		return
-	}
-
-	var v1 []float64
-	err = json.Unmarshal(gj.Coords, &v1)
-	if err == nil {
-		g.Coords[0][0][0] = v1
-		return
-	}
-
-	return
-
-}
-
-// GeoJSON 生成GeoJSON
-func (g Geo) GeoJSON() (s string, err error) {
-	s, err = g.CoordsJSON()
-	s = fmt.Sprintf(`{"type":"%s","coordinates":%s}`, g.Type, s)
-	return s, err
-}
-
-func (g Geo) CoordsJSON() (s string, err error) {
-	var b []byte
-	switch g.Type {
-	case "Point":
-		b, err = json.Marshal(g.Coords[0][0][0])
-	case "LineString", "MultiPoint":
-		b, err = json.Marshal(g.Coords[0][0])
-	case "Polygon", "MultiLineString":
-		b, err = json.Marshal(g.Coords[0])
-	case "MultiPolygon":
-		b, err = json.Marshal(g.Coords)
-	}
-	return string(b), err
-}
-
-func (g Geo) String() (wkt string) {
-	return g.ToWKT()
-}
-
-// ToWKT 生成WKT
-func (g Geo) ToWKT() (wkt string) {
-	coords := g.Coords
-	var points, polygon, multipolygon []string
-	for _, a := range coords {
-		polygon = make([]string, 0)
-		for _, b := range a {
-			points = make([]string, 0)
-			for _, c := range b {
-				points = append(points, fmt.Sprintf("%g %g", c[0], c[1]))
-			}
-			polygon = append(polygon, fmt.Sprintf("( %s)", strings.Join(points, ", ")))
-		}
-		multipolygon = append(multipolygon, fmt.Sprintf("(%s)", strings.Join(polygon, ", ")))
-	}
-
-	switch g.Type {
-	case "Point":
-		wkt = fmt.Sprintf("POINT (%s)", points[0])
-	case "MultiPoint":
-		wkt = fmt.Sprintf("MULTIPOINT (%s)", strings.Join(points, ","))
-	case "LineString":
-		wkt = fmt.Sprintf("LINESTRING (%s)", strings.Join(points, ","))
-	case "MultiLineString":
-		wkt = fmt.Sprintf("MULTILINESTRING (%s)", strings.Join(polygon, ","))
-	case "Polygon":
-		wkt = fmt.Sprintf("POLYGON (%s)", strings.Join(polygon, ","))
-	case "MultiPolygon":
-		wkt = fmt.Sprintf("MULTIPOLYGON (%s)", strings.Join(multipolygon, ","))
-	}
-	return
-}
-
-// PointFunc 对所有点应用函数
-func (g Geo) PointFunc(f func(lon, lat float64) (float64, float64)) {
-	coords := g.Coords
-	for _, a := range coords {
-		for _, b := range a {
-			for _, c := range b {
-				c[0], c[1] = f(c[0], c[1])
-			}
-		}
-	}
-}
-
-// FlipCoordinates 转换Lat,Lng 位置
-func (g Geo) FlipCoordinates() {
-	f := func(lon, lat float64) (float64, float64) { return lat, lon }
-	g.PointFunc(f)
-}
-
-// Wgs2gcj 经纬度坐标系转换 wgs-> gcj
-func (g Geo) Wgs2gcj() {
-	g.PointFunc(Wgs2gcj)
-}
-
-// Gcj2bd 经纬度坐标系转换 gcj->BD09
-func (g Geo) Gcj2bd() {
-	g.PointFunc(Gcj2bd)
-}
-
-// Wgs2bd 经纬度坐标系转换 wgs->BD09
-func (g Geo) Wgs2bd() {
-	g.PointFunc(Wgs2bd)
-}
-
-// PointRound6 PointRound6
-func (g Geo) PointRound6() {
-	g.PointFunc(PointRound6)
-}
-// PointRound7 PointRound7
-func (g Geo) PointRound7() {
-	g.PointFunc(PointRound7)
-}
-
-// PointRound8 PointRound8
-func (g Geo) PointRound8() {
-	g.PointFunc(PointRound8)
-}
-
-// Box 方框边界 minx, miny, maxx, maxy
-func (g Geo) Box() []float64 {
-	coords := g.Coords
-	minx, miny, maxx, maxy := coords[0][0][0][0], coords[0][0][0][1], coords[0][0][0][0], coords[0][0][0][1]
-	for _, a := range coords {
-		for _, b := range a {
-			for _, c := range b {
-				if c[0] > maxx {
-					maxx = c[0]
-				}
-				if c[0] < minx {
-					minx = c[0]
-				}
-				if c[1] > maxy {
-					maxy = c[1]
-				}
-				if c[1] < miny {
-					miny = c[1]
-				}
-			}
-		}
-	}
-	return []float64{minx, miny, maxx, maxy}
-}
-
-// IsClockwise  Green公式判断顺时针
-func IsClockwise(latlngs [][]float64) bool {
-	d := 0.0
-	n := len(latlngs)
-	for i := 0; i < n-1; i++ {
-		d += -0.5 * (latlngs[i][0] + latlngs[i+1][0]) * (latlngs[i+1][1] - latlngs[i][1])
-	}
-	if d > 0 {
-		return false //counter clockwise
-	}
-	return true // clockwise
-}
+package xutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Geo 几何对象, Coords 为空时表示 EMPTY, GeometryCollection 的成员存放在 Geoms
+// Dim 坐标维度: ""(XY) XYZ XYM XYZM, 坐标按 x y [z] [m] 顺序存放
+// CRS 坐标系名称, 如 "EPSG:4326" "GCJ02", 为空表示未知, 见 Transform
+type Geo struct {
+	Type   string
+	Coords [][][][]float64
+	Geoms  []Geo
+	Dim    string
+	CRS    string
+}
+
+var _geoTypes = map[string]string{"POINT": "Point", "LINESTRING": "LineString", "POLYGON": "Polygon", "MULTIPOINT": "MultiPoint",
+	"MULTILINESTRING": "MultiLineString", "MULTIPOLYGON": "MultiPolygon", "GEOMETRYCOLLECTION": "GeometryCollection"}
+
+var _geoDims = map[string]string{"Z": "XYZ", "M": "XYM", "ZM": "XYZM"}
+
+type Point struct {
+	X float64
+	Y float64
+}
+
+func (p Point) String() string {
+	return fmt.Sprintf("%g,%g", p.X, p.Y)
+}
+
+type Line struct {
+	P1 Point
+	P2 Point
+}
+
+//===============================================================================
+
+func NewPoint(x, y string) (p Point, err error) {
+	m, err := strconv.ParseFloat(x, 64)
+	if err != nil {
+		return p, err
+	}
+	n, err := strconv.ParseFloat(y, 64)
+	if err != nil {
+		return p, err
+	}
+	return Point{X: m, Y: n}, nil
+}
+
+func (p *Point) ReverseXY() {
+	p.X, p.Y = p.Y, p.X
+}
+
+func (p *Point) Wgs2gcj() {
+	p.X, p.Y = Wgs2gcj(p.X, p.Y)
+}
+
+func (p *Point) Wgs2bd() {
+	p.X, p.Y = Wgs2bd(p.X, p.Y)
+}
+
+func (p *Point) Gcj2bd() {
+	p.X, p.Y = Gcj2bd(p.X, p.Y)
+}
+
+//===============================================================================
+
+// Lines 所有线段(线的相邻点、面的环边), 点对象无线段
+func (g Geo) Lines() []Line {
+	lines := []Line{}
+	for _, sub := range g.Geoms {
+		lines = append(lines, sub.Lines()...)
+	}
+	if g.Type == "Point" || g.Type == "MultiPoint" {
+		return lines
+	}
+	for _, a := range g.Coords {
+		for _, b := range a {
+			for i := 1; i < len(b); i++ {
+				if len(b[i-1]) < 2 || len(b[i]) < 2 {
+					continue
+				}
+				lines = append(lines, Line{Point{b[i-1][0], b[i-1][1]}, Point{b[i][0], b[i][1]}})
+			}
+		}
+	}
+	return lines
+}
+
+func (g Geo) Points() []Point {
+	points := []Point{}
+	g.eachCoord(func(c []float64) {
+		points = append(points, Point{c[0], c[1]})
+	})
+	return points
+}
+
+// eachCoord 遍历所有坐标(含GeometryCollection成员), 跳过空点
+func (g Geo) eachCoord(f func(c []float64)) {
+	for _, sub := range g.Geoms {
+		sub.eachCoord(f)
+	}
+	for _, a := range g.Coords {
+		for _, b := range a {
+			for _, c := range b {
+				if len(c) >= 2 {
+					f(c)
+				}
+			}
+		}
+	}
+}
+
+// IsEmpty 是否为空几何对象
+func (g Geo) IsEmpty() bool {
+	empty := true
+	g.eachCoord(func(c []float64) { empty = false })
+	return empty
+}
+
+// HasZ 是否包含Z坐标
+func (g Geo) HasZ() bool {
+	return strings.Contains(g.Dim, "Z")
+}
+
+// HasM 是否包含M坐标
+func (g Geo) HasM() bool {
+	return strings.HasSuffix(g.Dim, "M")
+}
+
+// Stride 每个坐标的维数 2/3/4
+func (g Geo) Stride() int {
+	if g.Dim == "" {
+		return 2
+	}
+	return len(g.Dim)
+}
+
+// dimTag WKT维度标记 Z/M/ZM
+func (g Geo) dimTag() string {
+	return strings.TrimPrefix(g.Dim, "XY")
+}
+
+// inferDim 根据首个坐标推断维度, 3维视为XYZ
+func (g *Geo) inferDim() {
+	if g.Type == "GeometryCollection" {
+		// 成员维度一致时才设置
+		for i, sub := range g.Geoms {
+			if sub.Dim != g.Geoms[0].Dim {
+				return
+			}
+			if i == len(g.Geoms)-1 {
+				g.Dim = sub.Dim
+			}
+		}
+		return
+	}
+	n := 0
+	g.eachCoord(func(c []float64) {
+		if n == 0 {
+			n = len(c)
+		}
+	})
+	switch {
+	case n == 3:
+		g.Dim = "XYZ"
+	case n >= 4:
+		g.Dim = "XYZM"
+	}
+}
+
+// Copy CopyGeo
+func (g Geo) Copy() Geo {
+	var g1 Geo
+	g1.Type = g.Type
+	g1.Dim = g.Dim
+	g1.CRS = g.CRS
+	if g.Geoms != nil {
+		g1.Geoms = make([]Geo, len(g.Geoms))
+		for i, sub := range g.Geoms {
+			g1.Geoms[i] = sub.Copy()
+		}
+	}
+	if g.Coords == nil {
+		return g1
+	}
+	g1.Coords = make([][][][]float64, len(g.Coords))
+	for i, a := range g.Coords {
+		g1.Coords[i] = make([][][]float64, len(a))
+		for j, b := range a {
+			g1.Coords[i][j] = make([][]float64, len(b))
+			for k, c := range b {
+				g1.Coords[i][j][k] = make([]float64, len(c))
+				copy(g1.Coords[i][j][k], c)
+			}
+		}
+	}
+	return g1
+}
+
+//===============================================================================
+
+// FromWKT 解析WKT为Geo
+func FromWKT(wkt string) (g Geo, err error) {
+	p := &wktParser{toks: wktTokens(wkt)}
+	g, err = p.geometry()
+	if err != nil {
+		return g, fmt.Errorf("WKT解析失败: %s %v", wkt, err)
+	}
+	if p.pos < len(p.toks) {
+		return g, fmt.Errorf("WKT解析失败: %s 多余内容 %s", wkt, p.toks[p.pos])
+	}
+	return
+}
+
+// wktTokens WKT分词: 括号、逗号、单词/数字
+func wktTokens(wkt string) []string {
+	wkt = strings.NewReplacer("(", " ( ", ")", " ) ", ",", " , ").Replace(wkt)
+	return strings.Fields(wkt)
+}
+
+type wktParser struct {
+	toks   []string
+	pos    int
+	stride int // 当前几何对象的坐标维数, 0为未知
+}
+
+func (p *wktParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *wktParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *wktParser) expect(t string) error {
+	if w := p.next(); w != t {
+		return fmt.Errorf("期望 %q 实际 %q", t, w)
+	}
+	return nil
+}
+
+// empty 读取 EMPTY 关键字
+func (p *wktParser) empty() bool {
+	if strings.ToUpper(p.peek()) == "EMPTY" {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// list 解析 ( item, item, ... )
+func (p *wktParser) list(item func() error) error {
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	for {
+		if err := item(); err != nil {
+			return err
+		}
+		if p.peek() != "," {
+			break
+		}
+		p.pos++
+	}
+	return p.expect(")")
+}
+
+func (p *wktParser) coord() ([]float64, error) {
+	var c []float64
+	for t := p.peek(); t != "," && t != ")" && t != ""; t = p.peek() {
+		v, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			return nil, err
+		}
+		c = append(c, v)
+		p.pos++
+	}
+	if len(c) < 2 || len(c) > 4 {
+		return nil, fmt.Errorf("坐标维数错误 %v", c)
+	}
+	if p.stride == 0 {
+		p.stride = len(c)
+	} else if len(c) != p.stride {
+		return nil, fmt.Errorf("坐标维数不一致 %v", c)
+	}
+	return c, nil
+}
+
+// points 解析 (x y, x y, ...) 或 EMPTY
+func (p *wktParser) points() (ps [][]float64, err error) {
+	if p.empty() {
+		return [][]float64{}, nil
+	}
+	err = p.list(func() error {
+		c, err := p.coord()
+		ps = append(ps, c)
+		return err
+	})
+	return
+}
+
+// rings 解析 ((x y, ...), (x y, ...)) 或 EMPTY
+func (p *wktParser) rings() (rs [][][]float64, err error) {
+	if p.empty() {
+		return [][][]float64{}, nil
+	}
+	err = p.list(func() error {
+		ring, err := p.points()
+		rs = append(rs, ring)
+		return err
+	})
+	return
+}
+
+func (p *wktParser) geometry() (g Geo, err error) {
+	w := strings.ToUpper(p.next())
+	typ, ok := _geoTypes[w]
+	tag := strings.ToUpper(p.peek())
+	if _, isTag := _geoDims[tag]; ok && isTag {
+		p.pos++
+	} else if !ok {
+		// EWKT 形式 POINTM / LINESTRINGZM
+		for _, tag = range []string{"ZM", "Z", "M"} {
+			if typ, ok = _geoTypes[strings.TrimSuffix(w, tag)]; ok && strings.HasSuffix(w, tag) {
+				break
+			}
+		}
+		if !ok {
+			return g, fmt.Errorf("不支持的类型 %q", w)
+		}
+	} else {
+		tag = ""
+	}
+	g.Type = typ
+	g.Dim = _geoDims[tag]
+
+	saved := p.stride
+	p.stride = g.Stride()
+	if g.Dim == "" {
+		p.stride = 0
+	}
+	defer func() {
+		if g.Dim == "" {
+			g.inferDim()
+		}
+		p.stride = saved
+	}()
+	if p.empty() {
+		return g, nil
+	}
+
+	switch typ {
+	case "Point":
+		var ps [][]float64
+		if ps, err = p.points(); err == nil && len(ps) != 1 {
+			err = errors.New("POINT 只能有一个坐标")
+		}
+		g.Coords = [][][][]float64{{ps}}
+	case "LineString":
+		var ps [][]float64
+		ps, err = p.points()
+		g.Coords = [][][][]float64{{ps}}
+	case "Polygon", "MultiLineString":
+		var rs [][][]float64
+		rs, err = p.rings()
+		g.Coords = [][][][]float64{rs}
+	case "MultiPoint":
+		// MULTIPOINT (1 2, 3 4) 与 MULTIPOINT ((1 2), (3 4)) 均合法
+		var ps [][]float64
+		err = p.list(func() error {
+			if p.empty() {
+				ps = append(ps, []float64{})
+				return nil
+			}
+			paren := p.peek() == "("
+			if paren {
+				p.pos++
+			}
+			c, err := p.coord()
+			if err == nil && paren {
+				err = p.expect(")")
+			}
+			ps = append(ps, c)
+			return err
+		})
+		g.Coords = [][][][]float64{{ps}}
+	case "MultiPolygon":
+		err = p.list(func() error {
+			rs, err := p.rings()
+			g.Coords = append(g.Coords, rs)
+			return err
+		})
+	case "GeometryCollection":
+		g.Geoms = []Geo{}
+		err = p.list(func() error {
+			sub, err := p.geometry()
+			g.Geoms = append(g.Geoms, sub)
+			return err
+		})
+	}
+	return g, err
+}
+
+type geoJSONGeometry struct {
+	Type   string            `json:"type"`
+	Coords json.RawMessage   `json:"coordinates,omitempty"`
+	Geoms  []json.RawMessage `json:"geometries,omitempty"`
+}
+
+// FromGeoJSON 解析GeoJSON为Geo
+func FromGeoJSON(geojson string) (g Geo, err error) {
+	var gj geoJSONGeometry
+	err = json.Unmarshal([]byte(geojson), &gj)
+	if err != nil {
+		return g, err
+	}
+	return gj.geo()
+}
+
+func (gj geoJSONGeometry) geo() (g Geo, err error) {
+	typ, ok := _geoTypes[strings.ToUpper(gj.Type)]
+	if !ok {
+		return g, fmt.Errorf("不支持的GeoJSON类型: %s", gj.Type)
+	}
+	g.Type = typ
+	if typ == "GeometryCollection" {
+		g.Geoms = []Geo{}
+		for _, raw := range gj.Geoms {
+			sub, err := FromGeoJSON(string(raw))
+			if err != nil {
+				return g, err
+			}
+			g.Geoms = append(g.Geoms, sub)
+		}
+		return
+	}
+	if len(gj.Coords) == 0 || string(gj.Coords) == "null" {
+		return
+	}
+
+	switch typ {
+	case "Point":
+		var v1 []float64
+		if err = json.Unmarshal(gj.Coords, &v1); err == nil && len(v1) > 0 {
+			g.Coords = [][][][]float64{{{v1}}}
+		}
+	case "LineString", "MultiPoint":
+		var v2 [][]float64
+		if err = json.Unmarshal(gj.Coords, &v2); err == nil && len(v2) > 0 {
+			g.Coords = [][][][]float64{{v2}}
+		}
+	case "Polygon", "MultiLineString":
+		var v3 [][][]float64
+		if err = json.Unmarshal(gj.Coords, &v3); err == nil && len(v3) > 0 {
+			g.Coords = [][][][]float64{v3}
+		}
+	case "MultiPolygon":
+		var v4 [][][][]float64
+		if err = json.Unmarshal(gj.Coords, &v4); err == nil && len(v4) > 0 {
+			g.Coords = v4
+		}
+	}
+	g.inferDim()
+	return
+}
+
+// GeoJSON 生成GeoJSON
+func (g Geo) GeoJSON() (s string, err error) {
+	if g.Type == "GeometryCollection" {
+		geoms := make([]string, len(g.Geoms))
+		for i, sub := range g.Geoms {
+			if geoms[i], err = sub.GeoJSON(); err != nil {
+				return
+			}
+		}
+		return fmt.Sprintf(`{"type":"GeometryCollection","geometries":[%s]}`, strings.Join(geoms, ",")), nil
+	}
+	s, err = g.CoordsJSON()
+	s = fmt.Sprintf(`{"type":"%s","coordinates":%s}`, g.Type, s)
+	return s, err
+}
+
+// CoordsJSON 生成GeoJSON坐标, GeoJSON不支持M, XYM 输出时去掉M
+func (g Geo) CoordsJSON() (s string, err error) {
+	if g.IsEmpty() {
+		return "[]", nil
+	}
+	if g.Dim == "XYM" {
+		g = g.Force2D()
+	}
+	var b []byte
+	switch g.Type {
+	case "Point":
+		b, err = json.Marshal(g.Coords[0][0][0])
+	case "LineString", "MultiPoint":
+		b, err = json.Marshal(g.Coords[0][0])
+	case "Polygon", "MultiLineString":
+		b, err = json.Marshal(g.Coords[0])
+	case "MultiPolygon":
+		b, err = json.Marshal(g.Coords)
+	}
+	return string(b), err
+}
+
+func (g Geo) String() (wkt string) {
+	return g.ToWKT()
+}
+
+// ToWKT 生成WKT
+func (g Geo) ToWKT() (wkt string) {
+	name := strings.ToUpper(g.Type)
+	if tag := g.dimTag(); tag != "" {
+		name += " " + tag
+	}
+	if g.IsEmpty() {
+		return name + " EMPTY"
+	}
+	stride := g.Stride()
+	coord := func(c []float64) string {
+		if len(c) < 2 {
+			return "EMPTY"
+		}
+		s := make([]string, 0, stride)
+		for i := 0; i < stride && i < len(c); i++ {
+			s = append(s, strconv.FormatFloat(c[i], 'g', -1, 64))
+		}
+		return strings.Join(s, " ")
+	}
+	points := func(b [][]float64) []string {
+		s := make([]string, 0, len(b))
+		for _, c := range b {
+			s = append(s, coord(c))
+		}
+		return s
+	}
+	polygon := func(a [][][]float64) []string {
+		s := make([]string, 0, len(a))
+		for _, b := range a {
+			if len(b) == 0 {
+				s = append(s, "EMPTY")
+				continue
+			}
+			s = append(s, fmt.Sprintf("( %s)", strings.Join(points(b), ", ")))
+		}
+		return s
+	}
+
+	switch g.Type {
+	case "Point":
+		wkt = fmt.Sprintf("%s (%s)", name, coord(g.Coords[0][0][0]))
+	case "MultiPoint":
+		wkt = fmt.Sprintf("%s (%s)", name, strings.Join(points(g.Coords[0][0]), ","))
+	case "LineString":
+		wkt = fmt.Sprintf("%s (%s)", name, strings.Join(points(g.Coords[0][0]), ","))
+	case "MultiLineString":
+		wkt = fmt.Sprintf("%s (%s)", name, strings.Join(polygon(g.Coords[0]), ","))
+	case "Polygon":
+		wkt = fmt.Sprintf("%s (%s)", name, strings.Join(polygon(g.Coords[0]), ","))
+	case "MultiPolygon":
+		multipolygon := make([]string, 0, len(g.Coords))
+		for _, a := range g.Coords {
+			if len(a) == 0 {
+				multipolygon = append(multipolygon, "EMPTY")
+				continue
+			}
+			multipolygon = append(multipolygon, fmt.Sprintf("(%s)", strings.Join(polygon(a), ", ")))
+		}
+		wkt = fmt.Sprintf("%s (%s)", name, strings.Join(multipolygon, ","))
+	case "GeometryCollection":
+		geoms := make([]string, 0, len(g.Geoms))
+		for _, sub := range g.Geoms {
+			geoms = append(geoms, sub.ToWKT())
+		}
+		wkt = fmt.Sprintf("%s (%s)", name, strings.Join(geoms, ","))
+	}
+	return
+}
+
+// PointFunc 对所有点应用函数
+// 只修改x,y, 保留z,m
+func (g Geo) PointFunc(f func(lon, lat float64) (float64, float64)) {
+	g.eachCoord(func(c []float64) {
+		c[0], c[1] = f(c[0], c[1])
+	})
+}
+
+// CoordFunc 对所有坐标应用函数, c 包含 x y [z] [m]
+func (g Geo) CoordFunc(f func(c []float64)) {
+	g.eachCoord(f)
+}
+
+// Force2D 去掉z,m 返回XY副本
+func (g Geo) Force2D() Geo {
+	g1 := g.Copy()
+	g1.Dim = ""
+	for i, sub := range g1.Geoms {
+		g1.Geoms[i] = sub.Force2D()
+	}
+	for _, a := range g1.Coords {
+		for _, b := range a {
+			for k, c := range b {
+				if len(c) > 2 {
+					b[k] = c[:2]
+				}
+			}
+		}
+	}
+	return g1
+}
+
+// FlipCoordinates 转换Lat,Lng 位置
+func (g Geo) FlipCoordinates() {
+	f := func(lon, lat float64) (float64, float64) { return lat, lon }
+	g.PointFunc(f)
+}
+
+// 以下坐标系转换方法原地修改坐标, 不检查也不更新 CRS;
+// 设置了 CRS 的对象应使用 Transform, 否则 CRS 与坐标不一致, 之后再 Transform 会重复转换
+
+// Wgs2gcj 经纬度坐标系转换 wgs-> gcj
+func (g Geo) Wgs2gcj() {
+	g.PointFunc(Wgs2gcj)
+}
+
+// Gcj2bd 经纬度坐标系转换 gcj->BD09
+func (g Geo) Gcj2bd() {
+	g.PointFunc(Gcj2bd)
+}
+
+// Wgs2bd 经纬度坐标系转换 wgs->BD09
+func (g Geo) Wgs2bd() {
+	g.PointFunc(Wgs2bd)
+}
+
+// Gcj2Wgs 经纬度坐标系转换 gcj->wgs
+func (g Geo) Gcj2Wgs() {
+	g.PointFunc(Gcj2Wgs)
+}
+
+// Bd2gcj 经纬度坐标系转换 BD09->gcj
+func (g Geo) Bd2gcj() {
+	g.PointFunc(Bd2gcj)
+}
+
+// Bd2Wgs 经纬度坐标系转换 BD09->wgs
+func (g Geo) Bd2Wgs() {
+	g.PointFunc(Bd2Wgs)
+}
+
+// Cgcs2GK CGCS2000经纬度转高斯-克吕格平面坐标, 按边界中心确定带号, 东坐标带带号前缀
+func (g Geo) Cgcs2GK(width int) {
+	b := g.Box()
+	if b == nil {
+		return
+	}
+	zone, cm := GKZone((b[0]+b[2])/2, width)
+	g.PointFunc(func(lon, lat float64) (float64, float64) {
+		x, y := LonLat2GK(lon, lat, cm)
+		return x + float64(zone)*1e6, y
+	})
+}
+
+// GK2Cgcs 带带号前缀的高斯-克吕格平面坐标转CGCS2000经纬度
+func (g Geo) GK2Cgcs(width int) {
+	g.PointFunc(func(x, y float64) (float64, float64) { return GK2Cgcs(x, y, width) })
+}
+
+// Wgs2UTM WGS84经纬度转UTM平面坐标, 按边界中心确定带号及南北半球
+func (g Geo) Wgs2UTM() (zone int, north bool) {
+	b := g.Box()
+	if b == nil {
+		return 0, true
+	}
+	zone, north = UTMZone((b[0]+b[2])/2), (b[1]+b[3])/2 >= 0
+	g.PointFunc(func(lon, lat float64) (float64, float64) { return Wgs2UTMZone(lon, lat, zone, north) })
+	return zone, north
+}
+
+// UTM2Wgs UTM平面坐标转WGS84经纬度
+func (g Geo) UTM2Wgs(zone int, north bool) {
+	g.PointFunc(func(x, y float64) (float64, float64) { return UTM2Wgs(x, y, zone, north) })
+}
+
+// Wgs2WebMercator WGS84经纬度转Web墨卡托(EPSG:3857)
+func (g Geo) Wgs2WebMercator() {
+	g.PointFunc(Wgs2WebMercator)
+}
+
+// WebMercator2Wgs Web墨卡托(EPSG:3857)转WGS84经纬度
+func (g Geo) WebMercator2Wgs() {
+	g.PointFunc(WebMercator2Wgs)
+}
+
+// PointRound6 PointRound6
+func (g Geo) PointRound6() {
+	g.PointFunc(PointRound6)
+}
+// PointRound7 PointRound7
+func (g Geo) PointRound7() {
+	g.PointFunc(PointRound7)
+}
+
+// PointRound8 PointRound8
+func (g Geo) PointRound8() {
+	g.PointFunc(PointRound8)
+}
+
+// Box 方框边界 minx, miny, maxx, maxy, 空对象返回nil
+func (g Geo) Box() []float64 {
+	var box []float64
+	g.eachCoord(func(c []float64) {
+		if box == nil {
+			box = []float64{c[0], c[1], c[0], c[1]}
+			return
+		}
+		if c[0] > box[2] {
+			box[2] = c[0]
+		}
+		if c[0] < box[0] {
+			box[0] = c[0]
+		}
+		if c[1] > box[3] {
+			box[3] = c[1]
+		}
+		if c[1] < box[1] {
+			box[1] = c[1]
+		}
+	})
+	return box
+}
+
+// IsClockwise  Green公式判断顺时针
+func IsClockwise(latlngs [][]float64) bool {
+	d := 0.0
+	n := len(latlngs)
+	for i := 0; i < n-1; i++ {
+		d += -0.5 * (latlngs[i][0] + latlngs[i+1][0]) * (latlngs[i+1][1] - latlngs[i][1])
+	}
+	if d > 0 {
+		return false //counter clockwise
+	}
+	return true // clockwise
+}
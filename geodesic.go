@@ -0,0 +1,153 @@
+package xutil
+
+import (
+	"fmt"
+	"math"
+)
+
+/***
+椭球大地线
+https://en.wikipedia.org/wiki/Vincenty's_formulae
+https://www.movable-type.co.uk/scripts/latlong-vincenty.html
+	Vincenty 公式, 精度约0.5mm; 反算对近对跖点(两点经差、纬度和都接近180度)可能不收敛, 此时返回NaN, Interpolate 返回错误
+	方位角为度, 自北顺时针 0-360; azi2 为终点处沿大地线前进方向的方位角
+	GeodesicInverse GeodesicDirect GeodesicInterpolate 使用WGS84椭球, 其他椭球用 Ellipsoid 的同名方法
+***/
+
+// Inverse 大地线反算: 两点间距离(米)及起点、终点方位角
+func (e Ellipsoid) Inverse(lon1, lat1, lon2, lat2 float64) (dist, azi1, azi2 float64) {
+	b := e.A * (1 - e.F)
+	L := Radians(normLon(lon2 - lon1))
+	tanU1, tanU2 := (1-e.F)*math.Tan(Radians(lat1)), (1-e.F)*math.Tan(Radians(lat2))
+	cosU1, cosU2 := 1/math.Sqrt(1+tanU1*tanU1), 1/math.Sqrt(1+tanU2*tanU2)
+	sinU1, sinU2 := tanU1*cosU1, tanU2*cosU2
+
+	λ := L
+	var sinλ, cosλ, sinσ, cosσ, σ, cos2α, cos2σm float64
+	converged := false
+	for i := 0; i < 1000; i++ {
+		sinλ, cosλ = math.Sin(λ), math.Cos(λ)
+		sinσ = math.Hypot(cosU2*sinλ, cosU1*sinU2-sinU1*cosU2*cosλ)
+		if sinσ == 0 {
+			return 0, 0, 0 // 重合点
+		}
+		cosσ = sinU1*sinU2 + cosU1*cosU2*cosλ
+		σ = math.Atan2(sinσ, cosσ)
+		sinα := cosU1 * cosU2 * sinλ / sinσ
+		cos2α = 1 - sinα*sinα
+		cos2σm = 0 // 赤道线
+		if cos2α != 0 {
+			cos2σm = cosσ - 2*sinU1*sinU2/cos2α
+		}
+		C := e.F / 16 * cos2α * (4 + e.F*(4-3*cos2α))
+		λp := λ
+		λ = L + (1-C)*e.F*sinα*(σ+C*sinσ*(cos2σm+C*cosσ*(-1+2*cos2σm*cos2σm)))
+		if math.Abs(λ) > math.Pi+1e-12 {
+			break
+		}
+		if math.Abs(λ-λp) < 1e-12 {
+			converged = true
+			break
+		}
+	}
+	if !converged {
+		return math.NaN(), math.NaN(), math.NaN()
+	}
+
+	u2 := cos2α * (e.A*e.A - b*b) / (b * b)
+	A := 1 + u2/16384*(4096+u2*(-768+u2*(320-175*u2)))
+	B := u2 / 1024 * (256 + u2*(-128+u2*(74-47*u2)))
+	Δσ := B * sinσ * (cos2σm + B/4*(cosσ*(-1+2*cos2σm*cos2σm)-B/6*cos2σm*(-3+4*sinσ*sinσ)*(-3+4*cos2σm*cos2σm)))
+	dist = b * A * (σ - Δσ)
+	azi1 = Degrees(math.Atan2(cosU2*sinλ, cosU1*sinU2-sinU1*cosU2*cosλ))
+	azi2 = Degrees(math.Atan2(cosU1*sinλ, -sinU1*cosU2+cosU1*sinU2*cosλ))
+	return dist, normAzimuth(azi1), normAzimuth(azi2)
+}
+
+// Direct 大地线正算: 从起点沿方位角 azimuth 行进 dist 米的终点, 及终点方位角
+func (e Ellipsoid) Direct(lon, lat, dist, azimuth float64) (lon2, lat2, azi2 float64) {
+	b := e.A * (1 - e.F)
+	α1 := Radians(azimuth)
+	sinα1, cosα1 := math.Sin(α1), math.Cos(α1)
+	tanU1 := (1 - e.F) * math.Tan(Radians(lat))
+	cosU1 := 1 / math.Sqrt(1+tanU1*tanU1)
+	sinU1 := tanU1 * cosU1
+	σ1 := math.Atan2(tanU1, cosα1)
+	sinα := cosU1 * sinα1
+	cos2α := 1 - sinα*sinα
+	u2 := cos2α * (e.A*e.A - b*b) / (b * b)
+	A := 1 + u2/16384*(4096+u2*(-768+u2*(320-175*u2)))
+	B := u2 / 1024 * (256 + u2*(-128+u2*(74-47*u2)))
+
+	σ := dist / (b * A)
+	var sinσ, cosσ, cos2σm float64
+	for i := 0; i < 100; i++ {
+		cos2σm = math.Cos(2*σ1 + σ)
+		sinσ, cosσ = math.Sin(σ), math.Cos(σ)
+		Δσ := B * sinσ * (cos2σm + B/4*(cosσ*(-1+2*cos2σm*cos2σm)-B/6*cos2σm*(-3+4*sinσ*sinσ)*(-3+4*cos2σm*cos2σm)))
+		σp := σ
+		σ = dist/(b*A) + Δσ
+		if math.Abs(σ-σp) < 1e-12 {
+			break
+		}
+	}
+	sinσ, cosσ = math.Sin(σ), math.Cos(σ)
+	cos2σm = math.Cos(2*σ1 + σ)
+
+	x := sinU1*sinσ - cosU1*cosσ*cosα1
+	φ2 := math.Atan2(sinU1*cosσ+cosU1*sinσ*cosα1, (1-e.F)*math.Hypot(sinα, x))
+	λ := math.Atan2(sinσ*sinα1, cosU1*cosσ-sinU1*sinσ*cosα1)
+	C := e.F / 16 * cos2α * (4 + e.F*(4-3*cos2α))
+	L := λ - (1-C)*e.F*sinα*(σ+C*sinσ*(cos2σm+C*cosσ*(-1+2*cos2σm*cos2σm)))
+	return normLon(lon + Degrees(L)), Degrees(φ2), normAzimuth(Degrees(math.Atan2(sinα, -x)))
+}
+
+// Interpolate 沿大地线每隔 step 米插值, 返回包含起点、终点的 LineString, 反算不收敛(近对跖点)时返回错误
+func (e Ellipsoid) Interpolate(lon1, lat1, lon2, lat2, step float64) (Geo, error) {
+	dist, azi, _ := e.Inverse(lon1, lat1, lon2, lat2)
+	if math.IsNaN(dist) {
+		return Geo{}, fmt.Errorf("大地线反算不收敛(近对跖点): %g,%g %g,%g", lon1, lat1, lon2, lat2)
+	}
+	line := [][]float64{{lon1, lat1}}
+	if n := math.Ceil(dist / step); step > 0 && n > 1 {
+		for i := 1.0; i < n; i++ {
+			x, y, _ := e.Direct(lon1, lat1, dist*i/n, azi)
+			line = append(line, []float64{x, y})
+		}
+	}
+	line = append(line, []float64{lon2, lat2})
+	return Geo{Type: "LineString", Coords: [][][][]float64{{line}}}, nil
+}
+
+// normAzimuth 方位角归一化到 [0, 360)
+func normAzimuth(a float64) float64 {
+	a = math.Mod(a, 360)
+	if a < 0 {
+		a += 360
+	}
+	return a
+}
+
+// normLon 经度归一化到 [-180, 180)
+func normLon(lon float64) float64 {
+	return math.Mod(math.Mod(lon+180, 360)+360, 360) - 180
+}
+
+//===============================================================================
+
+// GeodesicInverse WGS84椭球两点间大地线距离(米)及起点、终点方位角
+func GeodesicInverse(lon1, lat1, lon2, lat2 float64) (dist, azi1, azi2 float64) {
+	return EllipsoidWGS84.Inverse(lon1, lat1, lon2, lat2)
+}
+
+// GeodesicDirect WGS84椭球上从起点沿方位角 azimuth 行进 dist 米的终点, 及终点方位角
+func GeodesicDirect(lon, lat, dist, azimuth float64) (lon2, lat2, azi2 float64) {
+	return EllipsoidWGS84.Direct(lon, lat, dist, azimuth)
+}
+
+// GeodesicInterpolate WGS84椭球上两点间大地线按 step 米加密为 LineString, 近对跖点时返回错误
+func GeodesicInterpolate(lon1, lat1, lon2, lat2, step float64) (Geo, error) {
+	g, err := EllipsoidWGS84.Interpolate(lon1, lat1, lon2, lat2, step)
+	g.CRS = CRSWGS84
+	return g, err
+}
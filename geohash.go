@@ -0,0 +1,135 @@
+package xutil
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+/***
+Geohash 编码
+https://en.wikipedia.org/wiki/Geohash
+	经度、纬度交替二分, 每5位转为一个base32字符, 精度1-12
+***/
+
+const _geohashBase32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// GeohashEncode 经纬度编码为geohash, precision 取值1-12, 超出范围时取12
+func GeohashEncode(lon, lat float64, precision int) string {
+	if precision < 1 || precision > 12 {
+		precision = 12
+	}
+	lonR, latR := [2]float64{-180, 180}, [2]float64{-90, 90}
+	var sb strings.Builder
+	bits, ch, even := 0, 0, true
+	for sb.Len() < precision {
+		r, v := &latR, lat
+		if even {
+			r, v = &lonR, lon
+		}
+		mid := (r[0] + r[1]) / 2
+		ch <<= 1
+		if v >= mid {
+			ch |= 1
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+		even = !even
+		if bits++; bits == 5 {
+			sb.WriteByte(_geohashBase32[ch])
+			bits, ch = 0, 0
+		}
+	}
+	return sb.String()
+}
+
+// geohashBox geohash对应的边界 minx, miny, maxx, maxy
+func geohashBox(hash string) ([]float64, error) {
+	if hash == "" {
+		return nil, fmt.Errorf("geohash为空")
+	}
+	lonR, latR := [2]float64{-180, 180}, [2]float64{-90, 90}
+	even := true
+	for _, c := range strings.ToLower(hash) {
+		n := strings.IndexRune(_geohashBase32, c)
+		if n < 0 {
+			return nil, fmt.Errorf("geohash字符错误: %s %q", hash, c)
+		}
+		for k := 4; k >= 0; k-- {
+			r := &latR
+			if even {
+				r = &lonR
+			}
+			mid := (r[0] + r[1]) / 2
+			if n>>uint(k)&1 == 1 {
+				r[0] = mid
+			} else {
+				r[1] = mid
+			}
+			even = !even
+		}
+	}
+	return []float64{lonR[0], latR[0], lonR[1], latR[1]}, nil
+}
+
+// GeohashDecode geohash解码为边界矩形 Polygon
+func GeohashDecode(hash string) (Geo, error) {
+	b, err := geohashBox(hash)
+	if err != nil {
+		return Geo{}, err
+	}
+	return boxGeo(b), nil
+}
+
+// boxGeo 边界 minx, miny, maxx, maxy 转为逆时针的 Polygon
+func boxGeo(b []float64) Geo {
+	return Geo{Type: "Polygon", Coords: [][][][]float64{{{
+		{b[0], b[1]}, {b[2], b[1]}, {b[2], b[3]}, {b[0], b[3]}, {b[0], b[1]},
+	}}}}
+}
+
+// GeohashNeighbors 相邻的8个geohash, 顺序为 N NE E SE S SW W NW, 超出南北极时为空字符串
+func GeohashNeighbors(hash string) ([8]string, error) {
+	var ns [8]string
+	b, err := geohashBox(hash)
+	if err != nil {
+		return ns, err
+	}
+	w, h := b[2]-b[0], b[3]-b[1]
+	x, y := (b[0]+b[2])/2, (b[1]+b[3])/2
+	for i, d := range [8][2]float64{{0, 1}, {1, 1}, {1, 0}, {1, -1}, {0, -1}, {-1, -1}, {-1, 0}, {-1, 1}} {
+		lat := y + d[1]*h
+		if lat < -90 || lat > 90 {
+			continue
+		}
+		lon := math.Mod(x+d[0]*w+540, 360) - 180
+		ns[i] = GeohashEncode(lon, lat, len(hash))
+	}
+	return ns, nil
+}
+
+// GeohashCover 与几何对象相交(含边界接触)的所有geohash, precision 取值1-12
+// 按格网宽高计算与边界闭区间相交的整数行列号范围, 逐个判断相交
+func GeohashCover(g Geo, precision int) []string {
+	b := g.Box()
+	if b == nil {
+		return nil
+	}
+	hash := GeohashEncode(b[0], b[1], precision)
+	cell, _ := geohashBox(hash)
+	w, h := cell[2]-cell[0], cell[3]-cell[1]
+	i0, i1 := int(math.Max(math.Ceil((b[0]+180)/w)-1, 0)), int(math.Min(math.Floor((b[2]+180)/w), math.Round(360/w)-1))
+	j0, j1 := int(math.Max(math.Ceil((b[1]+90)/h)-1, 0)), int(math.Min(math.Floor((b[3]+90)/h), math.Round(180/h)-1))
+	var hashes []string
+	for j := j0; j <= j1; j++ {
+		y := -90 + float64(j)*h
+		for i := i0; i <= i1; i++ {
+			x := -180 + float64(i)*w
+			if boxGeo([]float64{x, y, x + w, y + h}).Intersects(g) {
+				hashes = append(hashes, GeohashEncode(x+w/2, y+h/2, len(hash)))
+			}
+		}
+	}
+	return hashes
+}
@@ -97,10 +97,12 @@ func _offset(lon, lat float64) (float64, float64) {
 
 // Wgs2gcj WGS坐标系 ----> GCJ坐标系
 func Wgs2gcj(lon, lat float64) (float64, float64) {
+	return PointRound8(_wgs2gcj(lon, lat))
+}
+
+func _wgs2gcj(lon, lat float64) (float64, float64) {
 	dlon, dlat := _offset(lon, lat)
-	mglat := lat + dlat
-	mglon := lon + dlon
-	return PointRound8(mglon, mglat)
+	return lon + dlon, lat + dlat
 }
 
 // Gcj2Wgs  GCJ坐标系 ----> WGS坐标系
@@ -113,12 +115,14 @@ func Gcj2Wgs(lon, lat float64) (float64, float64) {
 
 // Gcj2bd  火星(GCJ-02)坐标系 ----> 百度(BD-09)坐标系
 func Gcj2bd(lon, lat float64) (float64, float64) {
+	return PointRound8(_gcj2bd(lon, lat))
+}
+
+func _gcj2bd(lon, lat float64) (float64, float64) {
 	x, y := lon, lat
 	z := math.Sqrt(x*x+y*y) + 0.00002*math.Sin(y*_xpi)
 	theta := math.Atan2(y, x) + 0.000003*math.Cos(x*_xpi)
-	bdLon := z*math.Cos(theta) + 0.0065
-	bdLat := z*math.Sin(theta) + 0.006
-	return PointRound8(bdLon, bdLat)
+	return z*math.Cos(theta) + 0.0065, z*math.Sin(theta) + 0.006
 }
 
 // Bd2gcj  百度(BD-09)坐标系 ----> 火星(GCJ-02)坐标系
@@ -143,6 +147,46 @@ func Bd2Wgs(lon, lat float64) (float64, float64) {
 	return Gcj2Wgs(x, y)
 }
 
+/*
+	高精度反算: Gcj2Wgs Bd2gcj Bd2Wgs 用一步近似, 误差可达数米
+	Exact 版本以正算函数迭代修正: w += 目标 - 正算(w), 直到修正量小于 tol(度), tol<=0 时取1e-9(约0.1毫米)
+	正算对所有经纬度都加偏移(不判断是否在国内), 反算同样处理
+*/
+
+// _inverse 迭代求正算函数 forward 的逆
+func _inverse(lon, lat, tol float64, forward func(lon, lat float64) (float64, float64)) (float64, float64) {
+	if tol <= 0 {
+		tol = 1e-9
+	}
+	x, y := lon, lat
+	for i := 0; i < 50; i++ {
+		fx, fy := forward(x, y)
+		dx, dy := lon-fx, lat-fy
+		x, y = x+dx, y+dy
+		if math.Abs(dx) < tol && math.Abs(dy) < tol {
+			break
+		}
+	}
+	return x, y
+}
+
+// Gcj2WgsExact GCJ坐标系 ----> WGS坐标系, 迭代反算, tol 为经纬度容差(度)
+func Gcj2WgsExact(lon, lat, tol float64) (float64, float64) {
+	return _inverse(lon, lat, tol, _wgs2gcj)
+}
+
+// Bd2gcjExact 百度(BD-09)坐标系 ----> 火星(GCJ-02)坐标系, 迭代反算, tol 为经纬度容差(度)
+func Bd2gcjExact(lon, lat, tol float64) (float64, float64) {
+	return _inverse(lon, lat, tol, _gcj2bd)
+}
+
+// Bd2WgsExact 百度坐标系 ----> WGS坐标系, 迭代反算, tol 为经纬度容差(度)
+func Bd2WgsExact(lon, lat, tol float64) (float64, float64) {
+	return _inverse(lon, lat, tol, func(lon, lat float64) (float64, float64) {
+		return _gcj2bd(_wgs2gcj(lon, lat))
+	})
+}
+
 //===============================================================================
 
 /***
@@ -248,6 +292,25 @@ func PointAt(lon, lat, dist, azimuth float64) (float64, float64) {
 	return Degrees(λ2), Degrees(φ2)
 }
 
+// CrossTrackDistance 点到 P1->P2 大圆的距离(米), 点在前进方向右侧为正, 左侧为负
+func CrossTrackDistance(lon, lat, lon1, lat1, lon2, lat2 float64) float64 {
+	δ13 := PointDistHaversine(lon1, lat1, lon, lat) / _earthR
+	θ := Radians(Azimuth(lon1, lat1, lon, lat) - Azimuth(lon1, lat1, lon2, lat2))
+	return math.Asin(math.Sin(δ13)*math.Sin(θ)) * _earthR
+}
+
+// AlongTrackDistance 点在 P1->P2 大圆上的垂足到P1的距离(米), 垂足位于P1背向P2一侧时为负
+func AlongTrackDistance(lon, lat, lon1, lat1, lon2, lat2 float64) float64 {
+	δ13 := PointDistHaversine(lon1, lat1, lon, lat) / _earthR
+	θ := Radians(Azimuth(lon1, lat1, lon, lat) - Azimuth(lon1, lat1, lon2, lat2))
+	δxt := math.Asin(math.Sin(δ13) * math.Sin(θ))
+	δat := math.Acos(math.Min(1, math.Cos(δ13)/math.Cos(δxt)))
+	if math.Cos(θ) < 0 {
+		δat = -δat
+	}
+	return δat * _earthR
+}
+
 //===============================================================================
 /*** 墨卡托坐标体系
 https://en.wikipedia.org/wiki/Web_Mercator
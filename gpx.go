@@ -0,0 +1,219 @@
+package xutil
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/***
+GPX 导入导出
+https://www.topografix.com/GPX/1/1/
+	FromGPX: wpt 转为 Point, rte 转为 LineString, trk 转为 LineString(单段) 或 MultiLineString
+		高度 ele 存为Z, 时间 time 存为M(Unix秒, 精确到毫秒); 所有点都有 ele/time 时才保留对应维度
+		name desc cmt sym type 等文本子元素作为字符串属性
+	ToGPX: 点输出为 wpt, 线输出为 trk, 面的各环作为 trk 的轨迹段
+***/
+
+// FromGPX 解析GPX中的航点、路线与轨迹
+func FromGPX(gpx string) (fc FeatureCollection, err error) {
+	root, err := parseXMLNode(gpx)
+	if err != nil {
+		return fc, err
+	}
+	if root.XMLName.Local != "gpx" {
+		return fc, fmt.Errorf("不是GPX: %s", root.XMLName.Local)
+	}
+	for _, n := range root.Nodes {
+		var g Geo
+		switch n.XMLName.Local {
+		case "wpt":
+			g, err = gpxGeo("Point", []xmlNode{n})
+		case "rte":
+			g, err = gpxGeo("LineString", gpxChildren(n, "rtept"))
+		case "trk":
+			var segs [][]xmlNode
+			for _, seg := range gpxChildren(n, "trkseg") {
+				segs = append(segs, gpxChildren(seg, "trkpt"))
+			}
+			g, err = gpxGeo("MultiLineString", segs...)
+		default:
+			continue
+		}
+		if err != nil {
+			return fc, err
+		}
+		f := NewFeature(g)
+		for _, c := range n.Nodes {
+			switch c.XMLName.Local {
+			case "ele", "time", "rtept", "trkseg", "extensions", "link":
+			default:
+				if len(c.Nodes) == 0 {
+					f.Properties[c.XMLName.Local] = strings.TrimSpace(c.Content)
+				}
+			}
+		}
+		fc.Features = append(fc.Features, f)
+	}
+	return fc, nil
+}
+
+func gpxChildren(n xmlNode, name string) []xmlNode {
+	var ns []xmlNode
+	for _, c := range n.Nodes {
+		if c.XMLName.Local == name {
+			ns = append(ns, c)
+		}
+	}
+	return ns
+}
+
+// gpxGeo 航点列表转Geo, MultiLineString 只有一段时为 LineString
+func gpxGeo(typ string, segs ...[]xmlNode) (g Geo, err error) {
+	hasZ, hasM := true, true
+	var lines [][][]float64
+	for _, seg := range segs {
+		var line [][]float64
+		for _, n := range seg {
+			c, err := gpxCoord(n)
+			if err != nil {
+				return g, err
+			}
+			hasZ, hasM = hasZ && !math.IsNaN(c[2]), hasM && !math.IsNaN(c[3])
+			line = append(line, c)
+		}
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return Geo{Type: typ}, nil
+	}
+
+	switch {
+	case hasZ && hasM:
+		g.Dim = "XYZM"
+	case hasZ:
+		g.Dim = "XYZ"
+	case hasM:
+		g.Dim = "XYM"
+	}
+	for _, line := range lines {
+		for i, c := range line {
+			switch g.Dim {
+			case "":
+				line[i] = c[:2]
+			case "XYZ":
+				line[i] = c[:3]
+			case "XYM":
+				line[i] = []float64{c[0], c[1], c[3]}
+			}
+		}
+	}
+	switch {
+	case typ == "Point":
+		g.Type, g.Coords = typ, [][][][]float64{{lines[0][:1]}}
+	case len(lines) == 1:
+		g.Type, g.Coords = "LineString", [][][][]float64{{lines[0]}}
+	default:
+		g.Type, g.Coords = typ, [][][][]float64{lines}
+	}
+	return g, nil
+}
+
+// gpxCoord 航点坐标 lon, lat, ele, time, 缺少 ele/time 时为NaN
+func gpxCoord(n xmlNode) ([]float64, error) {
+	c := []float64{0, 0, math.NaN(), math.NaN()}
+	for i, k := range []string{"lon", "lat"} {
+		v, err := strconv.ParseFloat(n.attr(k), 64)
+		if err != nil {
+			return nil, fmt.Errorf("GPX %s %s错误: %q", n.XMLName.Local, k, n.attr(k))
+		}
+		c[i] = v
+	}
+	if s := n.text("ele"); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("GPX ele错误: %q", s)
+		}
+		c[2] = v
+	}
+	if s := n.text("time"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("GPX time错误: %q", s)
+		}
+		c[3] = float64(t.UnixMilli()) / 1000
+	}
+	return c, nil
+}
+
+//===============================================================================
+
+// ToGPX 生成GPX文档
+func (fc FeatureCollection) ToGPX() (string, error) {
+	var wpts, trks []xmlNode
+	for _, f := range fc.Features {
+		g := f.Geometry
+		var segs [][][]float64
+		g.eachPart(func(c []float64) {
+			wpt := gpxPoint("wpt", c, g)
+			wpt.Nodes = append(wpt.Nodes, gpxProps(f.Properties, "name", "cmt", "desc", "src", "sym", "type")...)
+			wpts = append(wpts, wpt)
+		}, func(b [][]float64) {
+			segs = append(segs, b)
+		}, func(a [][][]float64) {
+			segs = append(segs, a...)
+		})
+		if len(segs) == 0 {
+			continue
+		}
+		trk := newXMLNode("trk", gpxProps(f.Properties, "name", "cmt", "desc", "src", "number", "type")...)
+		for _, seg := range segs {
+			s := newXMLNode("trkseg")
+			for _, c := range seg {
+				s.Nodes = append(s.Nodes, gpxPoint("trkpt", c, g))
+			}
+			trk.Nodes = append(trk.Nodes, s)
+		}
+		trks = append(trks, trk)
+	}
+	root := newXMLNode("gpx", append(wpts, trks...)...)
+	root.Attrs = []xml.Attr{
+		{Name: xml.Name{Local: "xmlns"}, Value: "http://www.topografix.com/GPX/1/1"},
+		{Name: xml.Name{Local: "version"}, Value: "1.1"},
+		{Name: xml.Name{Local: "creator"}, Value: "xutil"},
+	}
+	return marshalXMLNode(root)
+}
+
+// gpxPoint 坐标转航点元素, Z输出为 ele, M输出为 time
+func gpxPoint(name string, c []float64, g Geo) xmlNode {
+	n := newXMLNode(name)
+	n.Attrs = []xml.Attr{
+		{Name: xml.Name{Local: "lat"}, Value: strconv.FormatFloat(c[1], 'f', -1, 64)},
+		{Name: xml.Name{Local: "lon"}, Value: strconv.FormatFloat(c[0], 'f', -1, 64)},
+	}
+	if g.HasZ() && len(c) >= 3 {
+		n.Nodes = append(n.Nodes, newXMLText("ele", strconv.FormatFloat(c[2], 'f', -1, 64)))
+	}
+	if m := g.Stride() - 1; g.HasM() && len(c) > m {
+		t := time.UnixMilli(int64(math.Round(c[m] * 1000))).UTC()
+		n.Nodes = append(n.Nodes, newXMLText("time", t.Format(time.RFC3339Nano)))
+	}
+	return n
+}
+
+// gpxProps 按GPX元素顺序输出属性
+func gpxProps(props map[string]interface{}, keys ...string) []xmlNode {
+	var ns []xmlNode
+	for _, k := range keys {
+		if v, ok := props[k]; ok && v != nil {
+			ns = append(ns, newXMLText(k, propString(v)))
+		}
+	}
+	return ns
+}
@@ -0,0 +1,188 @@
+package xutil
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+/***
+规则格网(正方形/六边形)与点分箱统计
+https://www.redblobgames.com/grids/hexagons/  六边形坐标
+	以范围左下角为原点, 按中心纬度用 PointAt 换算每米对应的经纬度, 在局部平面(米)上划分格网
+	正方形: Size 为边长; 六边形: 尖顶, Size 为边长, 奇数行右移半个单元(odd-r)
+	单元编号为 "列_行", 原点所在单元为 0_0
+***/
+
+// Grid 规则格网
+type Grid struct {
+	Hex    bool    // 六边形, 否则为正方形
+	Size   float64 // 边长(米)
+	Origin Point   // 原点经纬度
+	Extent Geo     // 范围
+	kx, ky float64 // 每米对应的经度、纬度
+}
+
+// GridCell 格网单元
+type GridCell struct {
+	ID       string
+	Col, Row int
+	Geo      Geo
+}
+
+// NewSquareGrid 以正方形格网覆盖几何对象, size 为边长(米), 须大于0
+func NewSquareGrid(extent Geo, size float64) (*Grid, error) {
+	return newGrid(extent, size, false)
+}
+
+// NewHexGrid 以六边形格网覆盖几何对象, size 为边长(米), 须大于0
+func NewHexGrid(extent Geo, size float64) (*Grid, error) {
+	return newGrid(extent, size, true)
+}
+
+func newGrid(extent Geo, size float64, hex bool) (*Grid, error) {
+	if !(size > 0) || math.IsInf(size, 1) {
+		return nil, fmt.Errorf("格网边长须大于0: %g", size)
+	}
+	gr := &Grid{Hex: hex, Size: size, Extent: extent}
+	b := extent.Box()
+	if b == nil {
+		b = []float64{0, 0, 0, 0}
+	}
+	gr.Origin = Point{b[0], b[1]}
+	lon, lat := (b[0]+b[2])/2, (b[1]+b[3])/2
+	elon, _ := PointAt(lon, lat, 1000, 90)
+	_, nlat := PointAt(lon, lat, 1000, 0)
+	gr.kx, gr.ky = (elon-lon)/1000, (nlat-lat)/1000
+	return gr, nil
+}
+
+// xy 经纬度转局部平面坐标(米)
+func (gr *Grid) xy(lon, lat float64) (float64, float64) {
+	return (lon - gr.Origin.X) / gr.kx, (lat - gr.Origin.Y) / gr.ky
+}
+
+// Locate 点所在单元的列、行
+func (gr *Grid) Locate(lon, lat float64) (col, row int) {
+	x, y := gr.xy(lon, lat)
+	if !gr.Hex {
+		return int(math.Floor(x / gr.Size)), int(math.Floor(y / gr.Size))
+	}
+	// 转为轴向坐标后取整
+	q := (math.Sqrt(3)/3*x - y/3) / gr.Size
+	r := 2.0 / 3 * y / gr.Size
+	s := -q - r
+	rq, rr, rs := math.Round(q), math.Round(r), math.Round(s)
+	dq, dr, ds := math.Abs(rq-q), math.Abs(rr-r), math.Abs(rs-s)
+	if dq > dr && dq > ds {
+		rq = -rr - rs
+	} else if dr > ds {
+		rr = -rq - rs
+	}
+	row = int(rr)
+	return int(rq) + (row-(row&1))/2, row
+}
+
+// CellID 点所在单元的编号
+func (gr *Grid) CellID(lon, lat float64) string {
+	return gridID(gr.Locate(lon, lat))
+}
+
+func gridID(col, row int) string {
+	return fmt.Sprintf("%d_%d", col, row)
+}
+
+// Cell 指定列、行的单元
+func (gr *Grid) Cell(col, row int) GridCell {
+	var ring [][]float64
+	if gr.Hex {
+		w := math.Sqrt(3) * gr.Size
+		cx, cy := float64(col)*w+float64(row&1)*w/2, float64(row)*1.5*gr.Size
+		for i := 0; i < 6; i++ {
+			a := Radians(float64(60*i - 30))
+			ring = append(ring, gr.lonlat(cx+gr.Size*math.Cos(a), cy+gr.Size*math.Sin(a)))
+		}
+	} else {
+		x0, y0, x1, y1 := float64(col)*gr.Size, float64(row)*gr.Size, float64(col+1)*gr.Size, float64(row+1)*gr.Size
+		ring = [][]float64{gr.lonlat(x0, y0), gr.lonlat(x1, y0), gr.lonlat(x1, y1), gr.lonlat(x0, y1)}
+	}
+	ring = append(ring, ring[0])
+	return GridCell{ID: gridID(col, row), Col: col, Row: row, Geo: Geo{Type: "Polygon", Coords: [][][][]float64{{ring}}}}
+}
+
+func (gr *Grid) lonlat(x, y float64) []float64 {
+	return []float64{gr.Origin.X + x*gr.kx, gr.Origin.Y + y*gr.ky}
+}
+
+// Cells 与范围相交的所有单元, 按行、列排序
+func (gr *Grid) Cells() []GridCell {
+	b := gr.Extent.Box()
+	if b == nil || gr.Size <= 0 {
+		return nil
+	}
+	c0, r0 := gr.Locate(b[0], b[1])
+	c1, r1 := gr.Locate(b[2], b[3])
+	if gr.Hex {
+		// 六边形单元可能越过矩形范围, 向外扩一圈
+		c0, r0, c1, r1 = c0-1, r0-1, c1+1, r1+1
+	}
+	var cells []GridCell
+	for row := r0; row <= r1; row++ {
+		for col := c0; col <= c1; col++ {
+			if cell := gr.Cell(col, row); cell.Geo.Intersects(gr.Extent) {
+				cells = append(cells, cell)
+			}
+		}
+	}
+	return cells
+}
+
+//===============================================================================
+
+// GridBin 单元的统计值
+type GridBin struct {
+	ID       string
+	Col, Row int
+	Count    int
+	Sum      float64
+	Avg      float64
+	Min, Max float64
+}
+
+// GridBinner 点分箱统计, 逐点累加不保留原始值
+type GridBinner struct {
+	Grid *Grid
+	bins map[[2]int]*GridBin
+}
+
+// Binner 创建分箱统计
+func (gr *Grid) Binner() *GridBinner {
+	return &GridBinner{Grid: gr, bins: map[[2]int]*GridBin{}}
+}
+
+// Add 累加一个点的值, 返回所在单元编号
+func (b *GridBinner) Add(lon, lat, value float64) string {
+	col, row := b.Grid.Locate(lon, lat)
+	bin, ok := b.bins[[2]int{col, row}]
+	if !ok {
+		bin = &GridBin{ID: gridID(col, row), Col: col, Row: row, Min: value, Max: value}
+		b.bins[[2]int{col, row}] = bin
+	}
+	bin.Count++
+	bin.Sum += value
+	bin.Min, bin.Max = math.Min(bin.Min, value), math.Max(bin.Max, value)
+	bin.Avg = bin.Sum / float64(bin.Count)
+	return bin.ID
+}
+
+// Bins 所有有点的单元统计值, 按行、列排序
+func (b *GridBinner) Bins() []GridBin {
+	bins := make([]GridBin, 0, len(b.bins))
+	for _, bin := range b.bins {
+		bins = append(bins, *bin)
+	}
+	sort.Slice(bins, func(i, j int) bool {
+		return bins[i].Row < bins[j].Row || (bins[i].Row == bins[j].Row && bins[i].Col < bins[j].Col)
+	})
+	return bins
+}
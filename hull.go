@@ -0,0 +1,267 @@
+package xutil
+
+import (
+	"math"
+	"sort"
+)
+
+/***
+凸包、质心、面内点(平面, 经纬度直接按x,y计算)
+https://en.wikibooks.org/wiki/Algorithm_Implementation/Geometry/Convex_hull/Monotone_chain  凸包
+	Centroid: 有面时按面积加权, 有线时按长度加权, 否则取点的平均
+	PointOnSurface: 面取扫描线与面相交的最宽区间中点, 保证落在凹多边形内部
+***/
+
+// newPointGeo 创建坐标系为 crs 的点对象
+func newPointGeo(p Point, crs string) Geo {
+	return Geo{Type: "Point", Coords: [][][][]float64{{{{p.X, p.Y}}}}, CRS: crs}
+}
+
+// hasLine 是否包含线
+func (g Geo) hasLine() bool {
+	for _, sub := range g.Geoms {
+		if sub.hasLine() {
+			return true
+		}
+	}
+	return (g.Type == "LineString" || g.Type == "MultiLineString") && !g.IsEmpty()
+}
+
+// eachPart 遍历点、线、面组成部分(含GeometryCollection成员)
+func (g Geo) eachPart(point func(c []float64), line func(b [][]float64), polygon func(a [][][]float64)) {
+	for _, sub := range g.Geoms {
+		sub.eachPart(point, line, polygon)
+	}
+	switch g.Type {
+	case "Point", "MultiPoint":
+		for _, a := range g.Coords {
+			for _, b := range a {
+				for _, c := range b {
+					if len(c) >= 2 && point != nil {
+						point(c)
+					}
+				}
+			}
+		}
+	case "LineString", "MultiLineString":
+		for _, a := range g.Coords {
+			for _, b := range a {
+				if len(b) > 0 && line != nil {
+					line(b)
+				}
+			}
+		}
+	case "Polygon", "MultiPolygon":
+		for _, a := range g.Coords {
+			if len(a) > 0 && len(a[0]) > 0 && polygon != nil {
+				polygon(a)
+			}
+		}
+	}
+}
+
+// ConvexHull 凸包, 返回 Polygon(逆时针闭合), 共线时返回 LineString, 单点返回 Point
+func (g Geo) ConvexHull() Geo {
+	ps := g.Points()
+	sort.Slice(ps, func(i, j int) bool {
+		return ps[i].X < ps[j].X || (ps[i].X == ps[j].X && ps[i].Y < ps[j].Y)
+	})
+	uniq := ps[:0]
+	for i, p := range ps {
+		if i == 0 || !pointEqual(p, uniq[len(uniq)-1]) {
+			uniq = append(uniq, p)
+		}
+	}
+	ps = uniq
+	switch len(ps) {
+	case 0:
+		return Geo{Type: "Polygon", CRS: g.CRS}
+	case 1:
+		return newPointGeo(ps[0], g.CRS)
+	}
+
+	hull := make([]Point, 0, 2*len(ps))
+	for _, p := range ps {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	for i, lower := len(ps)-2, len(hull)+1; i >= 0; i-- {
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], ps[i]) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, ps[i])
+	}
+	if len(hull) < 4 {
+		// 所有点共线
+		a, b := ps[0], ps[len(ps)-1]
+		return Geo{Type: "LineString", Coords: [][][][]float64{{{{a.X, a.Y}, {b.X, b.Y}}}}, CRS: g.CRS}
+	}
+	ring := make([][]float64, len(hull))
+	for i, p := range hull {
+		ring[i] = []float64{p.X, p.Y}
+	}
+	return Geo{Type: "Polygon", Coords: [][][][]float64{{ring}}, CRS: g.CRS}
+}
+
+// ringCentroid 环的有向面积与面积矩
+func ringCentroid(ring [][]float64) (area, cx, cy float64) {
+	n := len(ring)
+	if n == 0 {
+		return
+	}
+	o := ring[0] // 以首点为原点减少精度损失
+	for i := 0; i < n; i++ {
+		a, b := ring[i], ring[(i+1)%n]
+		x0, y0, x1, y1 := a[0]-o[0], a[1]-o[1], b[0]-o[0], b[1]-o[1]
+		f := x0*y1 - x1*y0
+		area += f
+		cx += (x0 + x1) * f
+		cy += (y0 + y1) * f
+	}
+	area /= 2
+	if area != 0 {
+		cx, cy = cx/(6*area)+o[0], cy/(6*area)+o[1]
+	}
+	return
+}
+
+// Centroid 质心, 面按面积加权, 线按长度加权, 点取平均, 空对象返回 POINT EMPTY
+func (g Geo) Centroid() Geo {
+	var sx, sy, sw float64
+	switch {
+	case g.hasArea():
+		g.eachPart(nil, nil, func(a [][][]float64) {
+			for i, ring := range a {
+				area, cx, cy := ringCentroid(ring)
+				w := math.Abs(area)
+				if i > 0 {
+					w = -w // 内环扣除
+				}
+				sx, sy, sw = sx+cx*w, sy+cy*w, sw+w
+			}
+		})
+		if sw == 0 {
+			// 面积为0的退化面按边界线计算
+			return Geo{Type: "MultiLineString", Coords: [][][][]float64{g.rings()}, CRS: g.CRS}.Centroid()
+		}
+	case g.hasLine():
+		g.eachPart(nil, func(b [][]float64) {
+			for i := 1; i < len(b); i++ {
+				w := math.Hypot(b[i][0]-b[i-1][0], b[i][1]-b[i-1][1])
+				sx, sy, sw = sx+(b[i][0]+b[i-1][0])/2*w, sy+(b[i][1]+b[i-1][1])/2*w, sw+w
+			}
+		}, nil)
+		if sw == 0 {
+			return Geo{Type: "MultiPoint", Coords: [][][][]float64{{g.coords()}}, CRS: g.CRS}.Centroid()
+		}
+	default:
+		g.eachCoord(func(c []float64) {
+			sx, sy, sw = sx+c[0], sy+c[1], sw+1
+		})
+	}
+	if sw == 0 {
+		return Geo{Type: "Point", CRS: g.CRS}
+	}
+	return newPointGeo(Point{sx / sw, sy / sw}, g.CRS)
+}
+
+// rings 所有面的环
+func (g Geo) rings() (rings [][][]float64) {
+	g.eachPart(nil, nil, func(a [][][]float64) {
+		rings = append(rings, a...)
+	})
+	return
+}
+
+// coords 所有坐标
+func (g Geo) coords() (cs [][]float64) {
+	g.eachCoord(func(c []float64) {
+		cs = append(cs, c)
+	})
+	return
+}
+
+// PointOnSurface 保证落在几何对象上的点: 面取内部点, 线取最靠近质心的内部顶点, 点取最靠近质心的点
+func (g Geo) PointOnSurface() Geo {
+	if g.IsEmpty() {
+		return Geo{Type: "Point", CRS: g.CRS}
+	}
+	if g.hasArea() {
+		if p, ok := g.interiorPoint(); ok {
+			return newPointGeo(p, g.CRS)
+		}
+	}
+	c := coordPoint(g.Centroid().Coords[0][0][0])
+	best, bestd := Point{}, math.Inf(1)
+	try := func(x []float64) {
+		p := coordPoint(x)
+		if d := math.Hypot(p.X-c.X, p.Y-c.Y); d < bestd {
+			best, bestd = p, d
+		}
+	}
+	if g.hasLine() {
+		g.eachPart(nil, func(b [][]float64) {
+			for i := 1; i < len(b)-1; i++ {
+				try(b[i])
+			}
+		}, nil)
+		if math.IsInf(bestd, 1) {
+			g.eachPart(nil, func(b [][]float64) {
+				try(b[0])
+				try(b[len(b)-1])
+			}, nil)
+		}
+	} else {
+		g.eachCoord(try)
+	}
+	if math.IsInf(bestd, 1) {
+		g.eachCoord(try)
+	}
+	return newPointGeo(best, g.CRS)
+}
+
+// interiorPoint 面的内部点: 在每个多边形中部附近取一条不经过顶点的水平扫描线, 取最宽区间的中点
+func (g Geo) interiorPoint() (p Point, ok bool) {
+	widest := 0.0
+	g.eachPart(nil, nil, func(a [][][]float64) {
+		// 扫描线取外环y中值两侧相邻顶点y的中点, 避开顶点
+		ys := []float64{}
+		for _, ring := range a {
+			for _, c := range ring {
+				ys = append(ys, c[1])
+			}
+		}
+		sort.Float64s(ys)
+		mid := (ys[0] + ys[len(ys)-1]) / 2
+		lo, hi := ys[0], ys[len(ys)-1]
+		for _, y := range ys {
+			if y <= mid && y > lo {
+				lo = y
+			}
+			if y > mid && y < hi {
+				hi = y
+			}
+		}
+		y := (lo + hi) / 2
+
+		xs := []float64{}
+		for _, ring := range a {
+			n := len(ring)
+			for i, j := 0, n-1; i < n; j, i = i, i+1 {
+				p1, p2 := ring[j], ring[i]
+				if (p1[1] > y) != (p2[1] > y) {
+					xs = append(xs, p1[0]+(y-p1[1])*(p2[0]-p1[0])/(p2[1]-p1[1]))
+				}
+			}
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			if w := xs[i+1] - xs[i]; w > widest {
+				widest, p, ok = w, Point{(xs[i] + xs[i+1]) / 2, y}, true
+			}
+		}
+	})
+	return
+}
@@ -0,0 +1,374 @@
+package xutil
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/***
+KML 导入导出
+https://developers.google.com/kml/documentation/kmlreference
+	FromKML: 读取所有 Placemark(含 Folder 嵌套), 支持 Point LineString LinearRing Polygon MultiGeometry
+		id 作为要素ID, name description 及 ExtendedData 的 Data/SimpleData 作为字符串属性
+		坐标全部带高度时为 XYZ, 否则只取经纬度
+	ToKML: 属性 name description 输出为同名元素, 其余输出到 ExtendedData, Multi* 与 GeometryCollection 输出为 MultiGeometry
+***/
+
+// xmlNode 通用XML元素, 保留子元素顺序
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Content string     `xml:",chardata"`
+	Nodes   []xmlNode  `xml:",any"`
+}
+
+func newXMLNode(name string, nodes ...xmlNode) xmlNode {
+	return xmlNode{XMLName: xml.Name{Local: name}, Nodes: nodes}
+}
+
+func newXMLText(name, text string) xmlNode {
+	return xmlNode{XMLName: xml.Name{Local: name}, Content: text}
+}
+
+// attr 属性值
+func (n xmlNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// child 第一个指定名称的子元素
+func (n xmlNode) child(name string) (xmlNode, bool) {
+	for _, c := range n.Nodes {
+		if c.XMLName.Local == name {
+			return c, true
+		}
+	}
+	return xmlNode{}, false
+}
+
+// text 指定名称子元素的文本
+func (n xmlNode) text(name string) string {
+	c, _ := n.child(name)
+	return strings.TrimSpace(c.Content)
+}
+
+// walk 深度优先遍历指定名称的元素, 不进入匹配元素的内部
+func (n xmlNode) walk(name string, f func(xmlNode) error) error {
+	for _, c := range n.Nodes {
+		var err error
+		if c.XMLName.Local == name {
+			err = f(c)
+		} else {
+			err = c.walk(name, f)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseXMLNode(s string) (root xmlNode, err error) {
+	err = xml.Unmarshal([]byte(s), &root)
+	return
+}
+
+func marshalXMLNode(root xmlNode) (string, error) {
+	b, err := xml.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(b), nil
+}
+
+//===============================================================================
+
+// FromKML 解析KML中的所有 Placemark
+func FromKML(kml string) (fc FeatureCollection, err error) {
+	root, err := parseXMLNode(kml)
+	if err != nil {
+		return fc, err
+	}
+	err = root.walk("Placemark", func(pm xmlNode) error {
+		f := NewFeature(Geo{})
+		if id := pm.attr("id"); id != "" {
+			f.ID = id
+		}
+		var parts []Geo
+		for _, c := range pm.Nodes {
+			switch c.XMLName.Local {
+			case "name", "description":
+				f.Properties[c.XMLName.Local] = strings.TrimSpace(c.Content)
+			case "ExtendedData":
+				kmlExtendedData(c, f.Properties)
+			default:
+				g, ok, err := kmlGeo(c)
+				if err != nil {
+					return fmt.Errorf("Placemark %s: %v", pm.text("name"), err)
+				}
+				if ok {
+					parts = append(parts, g)
+				}
+			}
+		}
+		if len(parts) > 0 {
+			f.Geometry = parts[0]
+		}
+		fc.Features = append(fc.Features, f)
+		return nil
+	})
+	return fc, err
+}
+
+// kmlExtendedData 读取 Data 与 SchemaData/SimpleData
+func kmlExtendedData(n xmlNode, props map[string]interface{}) {
+	for _, c := range n.Nodes {
+		switch c.XMLName.Local {
+		case "Data":
+			props[c.attr("name")] = c.text("value")
+		case "SchemaData":
+			for _, sd := range c.Nodes {
+				if sd.XMLName.Local == "SimpleData" {
+					props[sd.attr("name")] = strings.TrimSpace(sd.Content)
+				}
+			}
+		}
+	}
+}
+
+// kmlGeo KML几何元素转Geo, 不是几何元素时 ok 为false
+func kmlGeo(n xmlNode) (g Geo, ok bool, err error) {
+	switch n.XMLName.Local {
+	case "Point":
+		cs, err := kmlCoords(n.text("coordinates"))
+		if err != nil || len(cs) == 0 {
+			return Geo{Type: "Point"}, true, err
+		}
+		return kmlDim(Geo{Type: "Point", Coords: [][][][]float64{{{cs[0]}}}}), true, nil
+	case "LineString", "LinearRing":
+		cs, err := kmlCoords(n.text("coordinates"))
+		if err != nil || len(cs) == 0 {
+			return Geo{Type: "LineString"}, true, err
+		}
+		return kmlDim(Geo{Type: "LineString", Coords: [][][][]float64{{cs}}}), true, nil
+	case "Polygon":
+		var rings [][][]float64
+		for _, c := range n.Nodes {
+			if c.XMLName.Local != "outerBoundaryIs" && c.XMLName.Local != "innerBoundaryIs" {
+				continue
+			}
+			for _, lr := range c.Nodes {
+				if lr.XMLName.Local != "LinearRing" {
+					continue
+				}
+				cs, err := kmlCoords(lr.text("coordinates"))
+				if err != nil {
+					return g, true, err
+				}
+				if c.XMLName.Local == "outerBoundaryIs" {
+					rings = append([][][]float64{cs}, rings...)
+				} else {
+					rings = append(rings, cs)
+				}
+			}
+		}
+		if len(rings) == 0 {
+			return Geo{Type: "Polygon"}, true, nil
+		}
+		return kmlDim(Geo{Type: "Polygon", Coords: [][][][]float64{rings}}), true, nil
+	case "MultiGeometry":
+		var parts []Geo
+		for _, c := range n.Nodes {
+			sub, ok, err := kmlGeo(c)
+			if err != nil {
+				return g, true, err
+			}
+			if ok {
+				parts = append(parts, sub)
+			}
+		}
+		return multiGeo(parts), true, nil
+	}
+	return g, false, nil
+}
+
+// kmlCoords 解析 "lon,lat[,alt] lon,lat[,alt] ..."
+func kmlCoords(s string) ([][]float64, error) {
+	var cs [][]float64
+	for _, t := range strings.Fields(s) {
+		vs := strings.Split(t, ",")
+		if len(vs) < 2 || len(vs) > 3 {
+			return nil, fmt.Errorf("KML坐标错误: %s", t)
+		}
+		c := make([]float64, len(vs))
+		for i, v := range vs {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("KML坐标错误: %s", t)
+			}
+			c[i] = f
+		}
+		cs = append(cs, c)
+	}
+	return cs, nil
+}
+
+// kmlDim 坐标全部带高度时为XYZ, 否则去掉高度
+func kmlDim(g Geo) Geo {
+	n := 3
+	g.eachCoord(func(c []float64) {
+		if len(c) < n {
+			n = len(c)
+		}
+	})
+	if n == 3 {
+		g.Dim = "XYZ"
+		return g
+	}
+	for _, a := range g.Coords {
+		for _, b := range a {
+			for i, c := range b {
+				b[i] = c[:2]
+			}
+		}
+	}
+	return g
+}
+
+// multiGeo 同类且同维度的成员合并为 Multi*, 否则为 GeometryCollection
+func multiGeo(parts []Geo) Geo {
+	if len(parts) == 0 {
+		return Geo{Type: "GeometryCollection"}
+	}
+	t, dim := parts[0].Type, parts[0].Dim
+	for _, p := range parts {
+		if p.Type != t || p.Dim != dim || strings.HasPrefix(t, "Multi") || t == "GeometryCollection" {
+			gc := Geo{Type: "GeometryCollection", Geoms: parts}
+			gc.inferDim()
+			return gc
+		}
+	}
+	g := Geo{Type: "Multi" + t, Dim: dim}
+	for _, p := range parts {
+		if len(p.Coords) == 0 {
+			continue
+		}
+		switch {
+		case t == "Polygon":
+			g.Coords = append(g.Coords, p.Coords[0])
+		case len(g.Coords) == 0:
+			g.Coords = [][][][]float64{{append([][]float64{}, p.Coords[0][0]...)}}
+		case t == "Point":
+			g.Coords[0][0] = append(g.Coords[0][0], p.Coords[0][0]...)
+		default:
+			g.Coords[0] = append(g.Coords[0], p.Coords[0]...)
+		}
+	}
+	return g
+}
+
+//===============================================================================
+
+// ToKML 生成KML文档
+func (fc FeatureCollection) ToKML() (string, error) {
+	doc := newXMLNode("Document")
+	for _, f := range fc.Features {
+		pm := newXMLNode("Placemark")
+		if f.ID != nil {
+			pm.Attrs = []xml.Attr{{Name: xml.Name{Local: "id"}, Value: fmt.Sprint(f.ID)}}
+		}
+		for _, k := range []string{"name", "description"} {
+			if v, ok := f.Properties[k]; ok {
+				pm.Nodes = append(pm.Nodes, newXMLText(k, propString(v)))
+			}
+		}
+		var keys []string
+		for k := range f.Properties {
+			if k != "name" && k != "description" {
+				keys = append(keys, k)
+			}
+		}
+		if len(keys) > 0 {
+			sort.Strings(keys)
+			ed := newXMLNode("ExtendedData")
+			for _, k := range keys {
+				d := newXMLNode("Data", newXMLText("value", propString(f.Properties[k])))
+				d.Attrs = []xml.Attr{{Name: xml.Name{Local: "name"}, Value: k}}
+				ed.Nodes = append(ed.Nodes, d)
+			}
+			pm.Nodes = append(pm.Nodes, ed)
+		}
+		if g, ok := geoKML(f.Geometry); ok {
+			pm.Nodes = append(pm.Nodes, g)
+		}
+		doc.Nodes = append(doc.Nodes, pm)
+	}
+	root := newXMLNode("kml", doc)
+	root.Attrs = []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: "http://www.opengis.net/kml/2.2"}}
+	return marshalXMLNode(root)
+}
+
+// propString 属性值转字符串
+func propString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// geoKML Geo转KML几何元素, 空几何对象返回 false
+func geoKML(g Geo) (xmlNode, bool) {
+	var parts []xmlNode
+	g.eachPart(func(c []float64) {
+		parts = append(parts, newXMLNode("Point", newXMLText("coordinates", kmlCoordsString([][]float64{c}, g.HasZ()))))
+	}, func(b [][]float64) {
+		parts = append(parts, newXMLNode("LineString", newXMLText("coordinates", kmlCoordsString(b, g.HasZ()))))
+	}, func(a [][][]float64) {
+		p := newXMLNode("Polygon")
+		for i, ring := range a {
+			name := "innerBoundaryIs"
+			if i == 0 {
+				name = "outerBoundaryIs"
+			}
+			p.Nodes = append(p.Nodes, newXMLNode(name, newXMLNode("LinearRing", newXMLText("coordinates", kmlCoordsString(ring, g.HasZ())))))
+		}
+		parts = append(parts, p)
+	})
+	switch {
+	case len(parts) == 0:
+		return xmlNode{}, false
+	case len(parts) == 1 && !strings.HasPrefix(g.Type, "Multi") && g.Type != "GeometryCollection":
+		return parts[0], true
+	}
+	return newXMLNode("MultiGeometry", parts...), true
+}
+
+// kmlCoordsString 坐标转 "lon,lat[,alt] ...", 有Z时输出高度
+func kmlCoordsString(cs [][]float64, hasZ bool) string {
+	var sb strings.Builder
+	for i, c := range cs {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(strconv.FormatFloat(c[0], 'f', -1, 64))
+		sb.WriteByte(',')
+		sb.WriteString(strconv.FormatFloat(c[1], 'f', -1, 64))
+		if hasZ && len(c) >= 3 {
+			sb.WriteByte(',')
+			sb.WriteString(strconv.FormatFloat(c[2], 'f', -1, 64))
+		}
+	}
+	return sb.String()
+}
@@ -261,7 +261,9 @@ func (m *MapAPI) BdmapGeoCodeALL(addrsMap map[string]string, poolsize int) (addr
 
 // mocator := "4|13534914.0122,3645387.5227;13535422.4951,3645834.93158|1-13534914.0122,3645542.22157,13534919.2537,3645555.85957,13535111.9804,3645672.21552,13535163.1497,3645713.32995;"
 
-//BmapMocator  百度墨卡托解析
+//BmapMocator  百度墨卡托解析, 返回 type 4 路径中的线(LINESTRING WKT), 保持原有输出
+//
+// Deprecated: 使用 FromBmapGeo 解析为 Geo, 支持点、线、面及多段路径
 func (m MapAPI) BmapMocator(mocator string) string {
 	if len(mocator) <= 0 {
 		return ""
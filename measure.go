@@ -0,0 +1,166 @@
+package xutil
+
+import (
+	"math"
+)
+
+/***
+几何对象量算(球面), 经纬度输入, 单位 米/平方米
+https://trs.jpl.nasa.gov/handle/2014/40409  Some Algorithms for Polygons on a Sphere (Chamberlain & Duquette)
+***/
+
+const _earthR = 6371000.0 // 地球平均半径, 与 PointDistance 一致
+
+// lineLength 折线长度
+func lineLength(line [][]float64) (d float64) {
+	for i := 1; i < len(line); i++ {
+		d += PointDistHaversine(line[i-1][0], line[i-1][1], line[i][0], line[i][1])
+	}
+	return
+}
+
+// RingArea 环的球面面积(平方米), 逆时针为正, 顺时针为负
+func RingArea(ring [][]float64) float64 {
+	n := len(ring)
+	if n < 3 {
+		return 0
+	}
+	if ring[0][0] == ring[n-1][0] && ring[0][1] == ring[n-1][1] {
+		n-- // 闭合环去掉重复的终点
+	}
+	total := 0.0
+	for i := 0; i < n; i++ {
+		lower, middle, upper := ring[i], ring[(i+1)%n], ring[(i+2)%n]
+		total += (Radians(upper[0]) - Radians(lower[0])) * math.Sin(Radians(middle[1]))
+	}
+	return -total * _earthR * _earthR / 2
+}
+
+// polygonArea 多边形面积, 扣除内环
+func polygonArea(rings [][][]float64) float64 {
+	area := 0.0
+	for i, ring := range rings {
+		if i == 0 {
+			area += math.Abs(RingArea(ring))
+		} else {
+			area -= math.Abs(RingArea(ring))
+		}
+	}
+	return area
+}
+
+// Length 线长度(米), LineString/MultiLineString 各线段之和, 面对象为0
+func (g Geo) Length() (d float64) {
+	for _, sub := range g.Geoms {
+		d += sub.Length()
+	}
+	switch g.Type {
+	case "LineString", "MultiLineString":
+		for _, a := range g.Coords {
+			for _, b := range a {
+				d += lineLength(b)
+			}
+		}
+	}
+	return
+}
+
+// Perimeter 周长(米), Polygon/MultiPolygon 所有环(含内环)长度之和
+func (g Geo) Perimeter() (d float64) {
+	for _, sub := range g.Geoms {
+		d += sub.Perimeter()
+	}
+	switch g.Type {
+	case "Polygon", "MultiPolygon":
+		for _, a := range g.Coords {
+			for _, b := range a {
+				d += lineLength(b)
+			}
+		}
+	}
+	return
+}
+
+// Area 球面面积(平方米), 扣除内环
+func (g Geo) Area() (area float64) {
+	for _, sub := range g.Geoms {
+		area += sub.Area()
+	}
+	switch g.Type {
+	case "Polygon", "MultiPolygon":
+		for _, a := range g.Coords {
+			area += polygonArea(a)
+		}
+	}
+	return
+}
+
+//===============================================================================
+
+// nearestOnSegment 大圆弧段ab上距点p最近的点及距离(米)
+func nearestOnSegment(p, a, b Point) (Point, float64) {
+	q := a
+	d12 := PointDistHaversine(a.X, a.Y, b.X, b.Y)
+	if dat := AlongTrackDistance(p.X, p.Y, a.X, a.Y, b.X, b.Y); d12 > 0 && dat >= d12 {
+		q = b
+	} else if d12 > 0 && dat > 0 {
+		// PointAt 按 _a 换算角距离
+		x, y := PointAt(a.X, a.Y, dat/_earthR*_a, Azimuth(a.X, a.Y, b.X, b.Y))
+		q = Point{normLon(x), y}
+	}
+	return q, PointDistHaversine(p.X, p.Y, q.X, q.Y)
+}
+
+// nearest 几何对象上距点p最近的点、线段序号及距离
+func (g Geo) nearest(p Point) (q Point, seg int, dist float64) {
+	seg, dist = -1, math.Inf(1)
+	inside := false
+	g.eachPart(func(c []float64) {
+		if d := PointDistHaversine(p.X, p.Y, c[0], c[1]); d < dist {
+			q, dist = coordPoint(c), d
+		}
+	}, nil, func(a [][][]float64) {
+		inside = inside || locateRings(a, p) == locInterior
+	})
+	if inside {
+		return p, -1, 0
+	}
+	for i, l := range g.Lines() {
+		if q1, d := nearestOnSegment(p, l.P1, l.P2); d < dist {
+			q, seg, dist = q1, i, d
+		}
+	}
+	return
+}
+
+// NearestPoint 几何对象上距点p最近的点, 及其所在线段在 Lines() 中的序号
+// 最近点为点对象的坐标或p在面内(返回p)时序号为-1, 空几何对象返回 -1
+func (g Geo) NearestPoint(p Point) (Point, int) {
+	q, seg, _ := g.nearest(p)
+	return q, seg
+}
+
+// DistanceTo 点到几何对象的球面距离(米), 线段按大圆弧计算, 点在面内时为0, 空几何对象为 +Inf
+func (g Geo) DistanceTo(p Point) float64 {
+	_, _, d := g.nearest(p)
+	return d
+}
+
+// DistanceToGeo 两几何对象间的最短球面距离(米), 相交时为0, 任一为空时为 +Inf
+func (g Geo) DistanceToGeo(o Geo) float64 {
+	if g.IsEmpty() || o.IsEmpty() {
+		return math.Inf(1)
+	}
+	if g.Intersects(o) {
+		return 0
+	}
+	// 不相交时最短距离在某一对象的顶点处取得
+	d := math.Inf(1)
+	for _, p := range g.Points() {
+		d = math.Min(d, o.DistanceTo(p))
+	}
+	for _, p := range o.Points() {
+		d = math.Min(d, g.DistanceTo(p))
+	}
+	return d
+}
@@ -0,0 +1,780 @@
+package xutil
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+/***
+Mapbox Vector Tile 编解码
+https://github.com/mapbox/vector-tile-spec/tree/master/2.1
+https://protobuf.dev/programming-guides/encoding/
+	经纬度按Web墨卡托投影到瓦片坐标, 依次简化、裁剪、取整后编码
+	面在瓦片坐标(y向下)中外环面积为正(顺时针), 内环为负
+	GeometryCollection 的成员拆分为多个要素, 只有第一个要素带ID; 要素ID只支持非负整数
+***/
+
+// MVTLayer 矢量瓦片图层
+type MVTLayer struct {
+	Name      string
+	Extent    int     // 瓦片坐标范围, 默认4096
+	Buffer    int     // 裁剪缓冲区(瓦片坐标)
+	Tolerance float64 // Douglas-Peucker 简化容差(瓦片坐标), 0 不简化
+	Features  []Feature
+}
+
+// MVT 几何类型与命令
+const (
+	mvtPoint      = 1
+	mvtLineString = 2
+	mvtPolygon    = 3
+
+	mvtMoveTo    = 1
+	mvtLineTo    = 2
+	mvtClosePath = 7
+)
+
+// EncodeMVT 编码瓦片 x, y, z 的矢量瓦片
+func EncodeMVT(layers []MVTLayer, x, y, z int) ([]byte, error) {
+	var tile pbWriter
+	for _, l := range layers {
+		if l.Name == "" {
+			return nil, errors.New("MVT图层名称为空")
+		}
+		tile.bytes(3, l.encode(x, y, z))
+	}
+	return tile.b, nil
+}
+
+func (l MVTLayer) encode(x, y, z int) []byte {
+	extent := l.Extent
+	if extent <= 0 {
+		extent = 4096
+	}
+	fwd := mvtProject(x, y, z, float64(extent))
+	lo, hi := float64(-l.Buffer), float64(extent+l.Buffer)
+	clip := [][][][]float64{{{{lo, lo}, {hi, lo}, {hi, hi}, {lo, hi}, {lo, lo}}}}
+
+	var layer pbWriter
+	layer.varint(15, 2)
+	layer.string(1, l.Name)
+	keys, values := map[string]int{}, map[string]int{}
+	var keyList []string
+	var valueList [][]byte
+	for _, f := range l.Features {
+		tags := mvtTags(f.Properties, keys, values, &keyList, &valueList)
+		id, hasID := mvtID(f.ID)
+		for _, g := range mvtParts(f.Geometry) {
+			typ, geom := mvtGeometry(g, fwd, l.Tolerance, lo, hi, clip)
+			if len(geom) == 0 {
+				continue
+			}
+			var feat pbWriter
+			if hasID {
+				feat.varint(1, id)
+				hasID = false // 图层内ID唯一, 只写在第一个成员上
+			}
+			feat.packed(2, tags)
+			feat.varint(3, uint64(typ))
+			feat.packed(4, geom)
+			layer.bytes(2, feat.b)
+		}
+	}
+	for _, k := range keyList {
+		layer.string(3, k)
+	}
+	for _, v := range valueList {
+		layer.bytes(4, v)
+	}
+	layer.varint(5, uint64(extent))
+	return layer.b
+}
+
+// mvtParts 拆分 GeometryCollection
+func mvtParts(g Geo) []Geo {
+	var parts []Geo
+	for _, sub := range g.Geoms {
+		parts = append(parts, mvtParts(sub)...)
+	}
+	if !g.IsEmpty() && len(g.Coords) > 0 {
+		parts = append(parts, g)
+	}
+	return parts
+}
+
+// mvtProject 经纬度转瓦片坐标
+func mvtProject(x, y, z int, extent float64) func(c []float64) []float64 {
+	n := math.Exp2(float64(z))
+	return func(c []float64) []float64 {
+		lat := Radians(math.Max(-_mercatorMaxLat, math.Min(_mercatorMaxLat, c[1])))
+		mx := (c[0] + 180) / 360 * n
+		my := (1 - math.Log(math.Tan(lat)+1/math.Cos(lat))/math.Pi) / 2 * n
+		return []float64{(mx - float64(x)) * extent, (my - float64(y)) * extent}
+	}
+}
+
+// mvtUnproject 瓦片坐标转经纬度
+func mvtUnproject(x, y, z int, extent float64) func(px, py float64) []float64 {
+	n := math.Exp2(float64(z))
+	return func(px, py float64) []float64 {
+		lon := (px/extent+float64(x))/n*360 - 180
+		lat := Degrees(math.Atan(math.Sinh(math.Pi * (1 - 2*(py/extent+float64(y))/n))))
+		return []float64{lon, lat}
+	}
+}
+
+// mvtGeometry 投影、简化、裁剪、取整后编码为命令序列
+func mvtGeometry(g Geo, fwd func(c []float64) []float64, tol, lo, hi float64, clip [][][][]float64) (int, []uint32) {
+	var e mvtEncoder
+	simplify := func(b [][]float64) [][]float64 {
+		out := make([][]float64, len(b))
+		for i, c := range b {
+			out[i] = fwd(c)
+		}
+		if tol > 0 {
+			out = simplifyDP(out, tol, coordPoint)
+		}
+		return out
+	}
+	switch g.Type {
+	case "Point", "MultiPoint":
+		var pts [][2]int64
+		g.eachCoord(func(c []float64) {
+			p := fwd(c)
+			if p[0] >= lo && p[0] <= hi && p[1] >= lo && p[1] <= hi {
+				pts = append(pts, [2]int64{int64(math.Round(p[0])), int64(math.Round(p[1]))})
+			}
+		})
+		if len(pts) > 0 {
+			e.command(mvtMoveTo, len(pts))
+			for _, p := range pts {
+				e.point(p)
+			}
+		}
+		return mvtPoint, e.geom
+	case "LineString", "MultiLineString":
+		g.eachPart(nil, func(b [][]float64) {
+			for _, part := range clipLine(simplify(b), lo, hi) {
+				if line := mvtRound(part); len(line) >= 2 {
+					e.command(mvtMoveTo, 1)
+					e.point(line[0])
+					e.command(mvtLineTo, len(line)-1)
+					for _, p := range line[1:] {
+						e.point(p)
+					}
+				}
+			}
+		}, nil)
+		return mvtLineString, e.geom
+	case "Polygon", "MultiPolygon":
+		var polys [][][][]float64
+		for _, rings := range g.Coords {
+			var poly [][][]float64
+			for i, ring := range rings {
+				r := simplify(ring)
+				if len(r) >= 4 {
+					poly = append(poly, r)
+				} else if i == 0 {
+					break
+				}
+			}
+			if len(poly) > 0 {
+				polys = append(polys, poly)
+			}
+		}
+		for _, rings := range overlay(polys, clip, ovIntersection) {
+			for i, ring := range rings {
+				r := mvtRound(ring[:len(ring)-1])
+				if len(r) < 3 || (mvtArea(r) > 0) != (i == 0) {
+					if i == 0 {
+						break
+					}
+					continue
+				}
+				e.command(mvtMoveTo, 1)
+				e.point(r[0])
+				e.command(mvtLineTo, len(r)-1)
+				for _, p := range r[1:] {
+					e.point(p)
+				}
+				e.command(mvtClosePath, 1)
+			}
+		}
+		return mvtPolygon, e.geom
+	}
+	return 0, nil
+}
+
+// clipLine Liang-Barsky 按正方形裁剪线, 返回落在范围内的各段
+func clipLine(line [][]float64, lo, hi float64) [][][]float64 {
+	var parts [][][]float64
+	var cur [][]float64
+	for i := 1; i < len(line); i++ {
+		a, b := line[i-1], line[i]
+		dx, dy := b[0]-a[0], b[1]-a[1]
+		t0, t1 := 0.0, 1.0
+		ok := true
+		for _, pq := range [4][2]float64{{-dx, a[0] - lo}, {dx, hi - a[0]}, {-dy, a[1] - lo}, {dy, hi - a[1]}} {
+			p, q := pq[0], pq[1]
+			if p == 0 {
+				ok = ok && q >= 0
+				continue
+			}
+			r := q / p
+			if p < 0 {
+				t0 = math.Max(t0, r)
+			} else {
+				t1 = math.Min(t1, r)
+			}
+		}
+		if !ok || t0 > t1 {
+			if cur != nil {
+				parts, cur = append(parts, cur), nil
+			}
+			continue
+		}
+		p0 := []float64{a[0] + t0*dx, a[1] + t0*dy}
+		p1 := []float64{a[0] + t1*dx, a[1] + t1*dy}
+		if cur == nil || t0 > 0 {
+			if cur != nil {
+				parts = append(parts, cur)
+			}
+			cur = [][]float64{p0}
+		}
+		cur = append(cur, p1)
+		if t1 < 1 {
+			parts, cur = append(parts, cur), nil
+		}
+	}
+	if cur != nil {
+		parts = append(parts, cur)
+	}
+	return parts
+}
+
+// mvtRound 取整并去掉连续重复点
+func mvtRound(b [][]float64) [][2]int64 {
+	var out [][2]int64
+	for _, c := range b {
+		p := [2]int64{int64(math.Round(c[0])), int64(math.Round(c[1]))}
+		if len(out) == 0 || out[len(out)-1] != p {
+			out = append(out, p)
+		}
+	}
+	if len(out) > 1 && out[0] == out[len(out)-1] {
+		out = out[:len(out)-1]
+	}
+	return out
+}
+
+// mvtArea 环的有向面积(瓦片坐标)
+func mvtArea(r [][2]int64) int64 {
+	var s int64
+	for i := range r {
+		a, b := r[i], r[(i+1)%len(r)]
+		s += a[0]*b[1] - b[0]*a[1]
+	}
+	return s
+}
+
+// mvtEncoder 几何命令编码, 坐标为相对上一点的增量
+type mvtEncoder struct {
+	geom   []uint32
+	cursor [2]int64
+}
+
+func (e *mvtEncoder) command(id, count int) {
+	e.geom = append(e.geom, uint32(id&0x7|count<<3))
+}
+
+func (e *mvtEncoder) point(p [2]int64) {
+	e.geom = append(e.geom, zigzag(p[0]-e.cursor[0]), zigzag(p[1]-e.cursor[1]))
+	e.cursor = p
+}
+
+func zigzag(v int64) uint32 {
+	return uint32((v << 1) ^ (v >> 63))
+}
+
+func unzigzag(v uint32) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// mvtID 非负整数ID
+func mvtID(id interface{}) (uint64, bool) {
+	switch v := id.(type) {
+	case int:
+		return uint64(v), v >= 0
+	case int64:
+		return uint64(v), v >= 0
+	case uint64:
+		return v, true
+	case float64:
+		return uint64(v), v >= 0 && v == math.Trunc(v)
+	case json.Number:
+		n, err := strconv.ParseUint(v.String(), 10, 64)
+		return n, err == nil
+	case string:
+		n, err := strconv.ParseUint(v, 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// mvtTags 属性转为键值索引, 键值表去重
+func mvtTags(props map[string]interface{}, keys, values map[string]int, keyList *[]string, valueList *[][]byte) []uint32 {
+	names := make([]string, 0, len(props))
+	for k, v := range props {
+		if v != nil {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	tags := make([]uint32, 0, 2*len(names))
+	for _, k := range names {
+		ki, ok := keys[k]
+		if !ok {
+			ki = len(*keyList)
+			keys[k] = ki
+			*keyList = append(*keyList, k)
+		}
+		vb := mvtValue(props[k])
+		vi, ok := values[string(vb)]
+		if !ok {
+			vi = len(*valueList)
+			values[string(vb)] = vi
+			*valueList = append(*valueList, vb)
+		}
+		tags = append(tags, uint32(ki), uint32(vi))
+	}
+	return tags
+}
+
+// mvtValue 属性值编码, 不支持的类型按字符串输出
+func mvtValue(v interface{}) []byte {
+	var w pbWriter
+	switch x := v.(type) {
+	case string:
+		w.string(1, x)
+	case float32:
+		w.tag(2, 5)
+		w.b = binary.LittleEndian.AppendUint32(w.b, math.Float32bits(x))
+	case float64:
+		w.tag(3, 1)
+		w.b = binary.LittleEndian.AppendUint64(w.b, math.Float64bits(x))
+	case int:
+		w.varint(4, uint64(x))
+	case int32:
+		w.varint(4, uint64(x))
+	case int64:
+		w.varint(4, uint64(x))
+	case uint:
+		w.varint(5, uint64(x))
+	case uint32:
+		w.varint(5, uint64(x))
+	case uint64:
+		w.varint(5, x)
+	case bool:
+		n := uint64(0)
+		if x {
+			n = 1
+		}
+		w.varint(7, n)
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return mvtValue(n)
+		}
+		if f, err := x.Float64(); err == nil {
+			return mvtValue(f)
+		}
+		w.string(1, x.String())
+	default:
+		w.string(1, fmt.Sprint(x))
+	}
+	return w.b
+}
+
+//===============================================================================
+
+// DecodeMVT 解码瓦片 x, y, z 的矢量瓦片, 坐标转换为经纬度
+func DecodeMVT(b []byte, x, y, z int) ([]MVTLayer, error) {
+	var layers []MVTLayer
+	r := pbReader{b: b}
+	for !r.done() {
+		field, wire, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if field != 3 || wire != 2 {
+			if err = r.skip(wire); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		lb, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+		l, err := decodeMVTLayer(lb, x, y, z)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, l)
+	}
+	return layers, nil
+}
+
+func decodeMVTLayer(b []byte, x, y, z int) (l MVTLayer, err error) {
+	type rawFeature struct {
+		id       uint64
+		hasID    bool
+		tags     []uint32
+		typ      uint64
+		geometry []uint32
+	}
+	var feats []rawFeature
+	var keys []string
+	var values []interface{}
+	l.Extent = 4096
+	r := pbReader{b: b}
+	for !r.done() {
+		field, wire, err := r.next()
+		if err != nil {
+			return l, err
+		}
+		switch {
+		case field == 1 && wire == 2:
+			s, err := r.bytes()
+			if err != nil {
+				return l, err
+			}
+			l.Name = string(s)
+		case field == 2 && wire == 2:
+			fb, err := r.bytes()
+			if err != nil {
+				return l, err
+			}
+			var f rawFeature
+			fr := pbReader{b: fb}
+			for !fr.done() {
+				ff, fw, err := fr.next()
+				if err != nil {
+					return l, err
+				}
+				switch {
+				case ff == 1 && fw == 0:
+					f.id, err = fr.varint()
+					f.hasID = true
+				case ff == 2 && fw == 2:
+					f.tags, err = fr.packed()
+				case ff == 3 && fw == 0:
+					f.typ, err = fr.varint()
+				case ff == 4 && fw == 2:
+					f.geometry, err = fr.packed()
+				default:
+					err = fr.skip(fw)
+				}
+				if err != nil {
+					return l, err
+				}
+			}
+			feats = append(feats, f)
+		case field == 3 && wire == 2:
+			s, err := r.bytes()
+			if err != nil {
+				return l, err
+			}
+			keys = append(keys, string(s))
+		case field == 4 && wire == 2:
+			vb, err := r.bytes()
+			if err != nil {
+				return l, err
+			}
+			v, err := decodeMVTValue(vb)
+			if err != nil {
+				return l, err
+			}
+			values = append(values, v)
+		case field == 5 && wire == 0:
+			n, err := r.varint()
+			if err != nil {
+				return l, err
+			}
+			l.Extent = int(n)
+		default:
+			if err = r.skip(wire); err != nil {
+				return l, err
+			}
+		}
+	}
+
+	inv := mvtUnproject(x, y, z, float64(l.Extent))
+	for _, rf := range feats {
+		f := NewFeature(Geo{})
+		if rf.hasID {
+			f.ID = rf.id
+		}
+		for i := 0; i+1 < len(rf.tags); i += 2 {
+			if int(rf.tags[i]) >= len(keys) || int(rf.tags[i+1]) >= len(values) {
+				return l, fmt.Errorf("MVT属性索引越界: %d %d", rf.tags[i], rf.tags[i+1])
+			}
+			f.Properties[keys[rf.tags[i]]] = values[rf.tags[i+1]]
+		}
+		if f.Geometry, err = decodeMVTGeometry(rf.typ, rf.geometry, inv); err != nil {
+			return l, err
+		}
+		l.Features = append(l.Features, f)
+	}
+	return l, nil
+}
+
+func decodeMVTValue(b []byte) (interface{}, error) {
+	r := pbReader{b: b}
+	var v interface{}
+	for !r.done() {
+		field, wire, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case field == 1 && wire == 2:
+			s, err := r.bytes()
+			if err != nil {
+				return nil, err
+			}
+			v = string(s)
+		case field == 2 && wire == 5:
+			n, err := r.fixed(4)
+			if err != nil {
+				return nil, err
+			}
+			v = float64(math.Float32frombits(uint32(n)))
+		case field == 3 && wire == 1:
+			n, err := r.fixed(8)
+			if err != nil {
+				return nil, err
+			}
+			v = math.Float64frombits(n)
+		case field >= 4 && field <= 7 && wire == 0:
+			n, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			switch field {
+			case 4:
+				v = int64(n)
+			case 5:
+				v = n
+			case 6:
+				v = int64(n>>1) ^ -int64(n&1)
+			case 7:
+				v = n != 0
+			}
+		default:
+			if err = r.skip(wire); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+// decodeMVTGeometry 解码几何命令, 面按环的面积符号区分外环和内环
+func decodeMVTGeometry(typ uint64, geom []uint32, inv func(px, py float64) []float64) (Geo, error) {
+	var parts [][][2]int64
+	var cursor [2]int64
+	for i := 0; i < len(geom); {
+		id, count := geom[i]&0x7, int(geom[i]>>3)
+		i++
+		switch id {
+		case mvtMoveTo, mvtLineTo:
+			if i+2*count > len(geom) {
+				return Geo{}, errors.New("MVT几何命令参数不足")
+			}
+			for k := 0; k < count; k++ {
+				cursor[0] += unzigzag(geom[i])
+				cursor[1] += unzigzag(geom[i+1])
+				i += 2
+				if id == mvtMoveTo || len(parts) == 0 {
+					parts = append(parts, nil)
+				}
+				parts[len(parts)-1] = append(parts[len(parts)-1], cursor)
+			}
+		case mvtClosePath:
+			if len(parts) > 0 && len(parts[len(parts)-1]) > 0 {
+				parts[len(parts)-1] = append(parts[len(parts)-1], parts[len(parts)-1][0])
+			}
+		default:
+			return Geo{}, fmt.Errorf("MVT几何命令错误: %d", id)
+		}
+	}
+	coords := func(part [][2]int64) [][]float64 {
+		cs := make([][]float64, len(part))
+		for i, p := range part {
+			cs[i] = inv(float64(p[0]), float64(p[1]))
+		}
+		return cs
+	}
+
+	switch typ {
+	case mvtPoint:
+		var pts [][]float64
+		for _, part := range parts {
+			pts = append(pts, coords(part)...)
+		}
+		g := Geo{Type: "MultiPoint", Coords: [][][][]float64{{pts}}}
+		if len(pts) == 1 {
+			g.Type = "Point"
+		}
+		return g, nil
+	case mvtLineString:
+		var lines [][][]float64
+		for _, part := range parts {
+			lines = append(lines, coords(part))
+		}
+		g := Geo{Type: "MultiLineString", Coords: [][][][]float64{lines}}
+		if len(lines) == 1 {
+			g.Type = "LineString"
+		}
+		return g, nil
+	case mvtPolygon:
+		var polys [][][][]float64
+		for _, part := range parts {
+			if a := mvtArea(part); a > 0 || len(polys) == 0 {
+				polys = append(polys, [][][]float64{coords(part)})
+			} else if a < 0 {
+				polys[len(polys)-1] = append(polys[len(polys)-1], coords(part))
+			}
+		}
+		return polysGeo(polys), nil
+	}
+	return Geo{}, fmt.Errorf("MVT几何类型错误: %d", typ)
+}
+
+//===============================================================================
+
+// pbWriter protobuf 编码
+type pbWriter struct {
+	b []byte
+}
+
+func (w *pbWriter) tag(field, wire int) {
+	w.b = binary.AppendUvarint(w.b, uint64(field<<3|wire))
+}
+
+func (w *pbWriter) varint(field int, v uint64) {
+	w.tag(field, 0)
+	w.b = binary.AppendUvarint(w.b, v)
+}
+
+func (w *pbWriter) bytes(field int, b []byte) {
+	w.tag(field, 2)
+	w.b = binary.AppendUvarint(w.b, uint64(len(b)))
+	w.b = append(w.b, b...)
+}
+
+func (w *pbWriter) string(field int, s string) {
+	w.bytes(field, []byte(s))
+}
+
+func (w *pbWriter) packed(field int, vs []uint32) {
+	if len(vs) == 0 {
+		return
+	}
+	var p []byte
+	for _, v := range vs {
+		p = binary.AppendUvarint(p, uint64(v))
+	}
+	w.bytes(field, p)
+}
+
+// pbReader protobuf 解码
+type pbReader struct {
+	b   []byte
+	off int
+}
+
+var errPBTruncated = errors.New("protobuf数据不完整")
+
+func (r *pbReader) done() bool {
+	return r.off >= len(r.b)
+}
+
+func (r *pbReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.b[r.off:])
+	if n <= 0 {
+		return 0, errPBTruncated
+	}
+	r.off += n
+	return v, nil
+}
+
+func (r *pbReader) next() (field, wire int, err error) {
+	v, err := r.varint()
+	return int(v >> 3), int(v & 0x7), err
+}
+
+func (r *pbReader) bytes() ([]byte, error) {
+	n, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(r.b)-r.off) < n {
+		return nil, errPBTruncated
+	}
+	b := r.b[r.off : r.off+int(n)]
+	r.off += int(n)
+	return b, nil
+}
+
+func (r *pbReader) fixed(size int) (uint64, error) {
+	if len(r.b)-r.off < size {
+		return 0, errPBTruncated
+	}
+	var v uint64
+	if size == 4 {
+		v = uint64(binary.LittleEndian.Uint32(r.b[r.off:]))
+	} else {
+		v = binary.LittleEndian.Uint64(r.b[r.off:])
+	}
+	r.off += size
+	return v, nil
+}
+
+func (r *pbReader) packed() ([]uint32, error) {
+	b, err := r.bytes()
+	if err != nil {
+		return nil, err
+	}
+	var vs []uint32
+	for len(b) > 0 {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errPBTruncated
+		}
+		vs = append(vs, uint32(v))
+		b = b[n:]
+	}
+	return vs, nil
+}
+
+func (r *pbReader) skip(wire int) (err error) {
+	switch wire {
+	case 0:
+		_, err = r.varint()
+	case 1:
+		_, err = r.fixed(8)
+	case 2:
+		_, err = r.bytes()
+	case 5:
+		_, err = r.fixed(4)
+	default:
+		err = fmt.Errorf("protobuf类型错误: %d", wire)
+	}
+	return
+}
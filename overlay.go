@@ -0,0 +1,470 @@
+package xutil
+
+import (
+	"math"
+	"sort"
+)
+
+/***
+面的叠加分析(平面), 交、并、差、对称差
+	1. 所有边两两求交点并打断, 重合的边合并, 记录每个图层中该边的方向计数
+	2. 每条边取中点做射线, 求边两侧在各图层中的环绕数(winding number)
+	3. 两侧填充状态不同的边构成结果的边界, 方向保证结果在左侧
+	4. 在结点处取入边反方向顺时针的第一条出边连接成环, 逆时针为外环, 顺时针为内环
+***/
+
+const _ovSnap = 1e-9 // 结点合并容差(度)
+
+// 叠加操作
+const (
+	ovUnion = iota
+	ovIntersection
+	ovDifference
+	ovSymDifference
+	ovParity // 仅第一个图层, 奇偶规则填充
+)
+
+// ovEdge 输入的有向边
+type ovEdge struct {
+	a, b  Point
+	layer int
+}
+
+// ovSeg 打断后的边, a < b, cnt 为各图层中 a->b 方向的边数减去反方向的边数
+type ovSeg struct {
+	a, b Point
+	cnt  [2]int
+}
+
+// overlay 两组多边形的叠加, 返回外环逆时针、内环顺时针的多边形
+func overlay(a, b [][][][]float64, op int) [][][][]float64 {
+	edges := ovEdges(a, 0, nil)
+	edges = ovEdges(b, 1, edges)
+	var fill func(w [2]int) bool
+	switch op {
+	case ovUnion:
+		fill = func(w [2]int) bool { return w[0] > 0 || w[1] > 0 }
+	case ovIntersection:
+		fill = func(w [2]int) bool { return w[0] > 0 && w[1] > 0 }
+	case ovDifference:
+		fill = func(w [2]int) bool { return w[0] > 0 && w[1] <= 0 }
+	case ovSymDifference:
+		fill = func(w [2]int) bool { return (w[0] > 0) != (w[1] > 0) }
+	case ovParity:
+		fill = func(w [2]int) bool { return w[0]%2 != 0 }
+	}
+	return ovBuild(ovNoding(edges), fill)
+}
+
+// polys 所有面的坐标
+func (g Geo) polys() (polys [][][][]float64) {
+	g.eachPart(nil, nil, func(a [][][]float64) {
+		polys = append(polys, a)
+	})
+	return
+}
+
+// polysGeo 多边形坐标转为 Polygon 或 MultiPolygon
+func polysGeo(polys [][][][]float64) Geo {
+	switch len(polys) {
+	case 0:
+		return Geo{Type: "Polygon"}
+	case 1:
+		return Geo{Type: "Polygon", Coords: polys}
+	}
+	return Geo{Type: "MultiPolygon", Coords: polys}
+}
+
+// overlayGeo 面的叠加, 结果坐标系与g相同
+func (g Geo) overlayGeo(o Geo, op int) Geo {
+	g1 := polysGeo(overlay(g.polys(), o.polys(), op))
+	g1.CRS = g.CRS
+	return g1
+}
+
+// Intersection 交集, 只计算面, 返回 Polygon 或 MultiPolygon
+func (g Geo) Intersection(o Geo) Geo {
+	if !boxIntersects(g.Box(), o.Box()) {
+		return Geo{Type: "Polygon", CRS: g.CRS}
+	}
+	return g.overlayGeo(o, ovIntersection)
+}
+
+// Union 并集, 只计算面, 返回 Polygon 或 MultiPolygon
+func (g Geo) Union(o Geo) Geo {
+	return g.overlayGeo(o, ovUnion)
+}
+
+// Difference 差集 g-o, 只计算面, 返回 Polygon 或 MultiPolygon
+func (g Geo) Difference(o Geo) Geo {
+	return g.overlayGeo(o, ovDifference)
+}
+
+// SymDifference 对称差, 只计算面, 返回 Polygon 或 MultiPolygon
+func (g Geo) SymDifference(o Geo) Geo {
+	return g.overlayGeo(o, ovSymDifference)
+}
+
+// UnionAll 合并所有面, 返回 Polygon 或 MultiPolygon, 坐标系与第一个对象相同
+func UnionAll(gs []Geo) Geo {
+	parts := make([][][][][]float64, 0, len(gs))
+	for _, g := range gs {
+		if ps := g.polys(); len(ps) > 0 {
+			parts = append(parts, ps)
+		}
+	}
+	g1 := polysGeo(unionPolys(parts))
+	if len(gs) > 0 {
+		g1.CRS = gs[0].CRS
+	}
+	return g1
+}
+
+//===============================================================================
+
+// unionPolys 逐级两两合并多组多边形
+func unionPolys(parts [][][][][]float64) [][][][]float64 {
+	if len(parts) == 0 {
+		return nil
+	}
+	if len(parts) == 1 {
+		return overlay(parts[0], nil, ovUnion)
+	}
+	for len(parts) > 1 {
+		next := make([][][][][]float64, 0, (len(parts)+1)/2)
+		for i := 0; i < len(parts); i += 2 {
+			if i+1 < len(parts) {
+				next = append(next, overlay(parts[i], parts[i+1], ovUnion))
+			} else {
+				next = append(next, parts[i])
+			}
+		}
+		parts = next
+	}
+	return parts[0]
+}
+
+// ovEdges 多边形的边, 外环统一为逆时针, 内环为顺时针
+func ovEdges(polys [][][][]float64, layer int, edges []ovEdge) []ovEdge {
+	for _, rings := range polys {
+		for i, ring := range rings {
+			ccw := ringSignedArea(ring) > 0
+			n := len(ring)
+			for k := 0; k < n; k++ {
+				a, b := coordPoint(ring[k]), coordPoint(ring[(k+1)%n])
+				if a == b {
+					continue
+				}
+				if ccw != (i == 0) {
+					a, b = b, a
+				}
+				edges = append(edges, ovEdge{a, b, layer})
+			}
+		}
+	}
+	return edges
+}
+
+// ringSignedArea 环的有向面积(平面), 逆时针为正
+func ringSignedArea(ring [][]float64) float64 {
+	s := 0.0
+	for i, n := 0, len(ring); i < n; i++ {
+		a, b := ring[i], ring[(i+1)%n]
+		s += a[0]*b[1] - b[0]*a[1]
+	}
+	return s / 2
+}
+
+//===============================================================================
+
+// ovSnapper 合并距离小于 _ovSnap 的结点
+type ovSnapper map[[2]int64][]Point
+
+func (s ovSnapper) snap(p Point) Point {
+	cx, cy := int64(math.Floor(p.X/_ovSnap)), int64(math.Floor(p.Y/_ovSnap))
+	for i := cx - 1; i <= cx+1; i++ {
+		for j := cy - 1; j <= cy+1; j++ {
+			for _, q := range s[[2]int64{i, j}] {
+				if math.Abs(p.X-q.X) <= _ovSnap && math.Abs(p.Y-q.Y) <= _ovSnap {
+					return q
+				}
+			}
+		}
+	}
+	k := [2]int64{cx, cy}
+	s[k] = append(s[k], p)
+	return p
+}
+
+// ovNoding 在交点处打断所有边, 合并重合的边
+func ovNoding(edges []ovEdge) []ovSeg {
+	snap := ovSnapper{}
+	for i := range edges {
+		edges[i].a, edges[i].b = snap.snap(edges[i].a), snap.snap(edges[i].b)
+	}
+	minx := func(e ovEdge) float64 { return math.Min(e.a.X, e.b.X) }
+	idx := make([]int, len(edges))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(i, j int) bool { return minx(edges[idx[i]]) < minx(edges[idx[j]]) })
+
+	// 按x扫描求交点
+	cuts := make([][]Point, len(edges))
+	for n, i := range idx {
+		e := edges[i]
+		maxx := math.Max(e.a.X, e.b.X) + _ovSnap
+		miny, maxy := math.Min(e.a.Y, e.b.Y)-_ovSnap, math.Max(e.a.Y, e.b.Y)+_ovSnap
+		for _, j := range idx[n+1:] {
+			f := edges[j]
+			if minx(f) > maxx {
+				break
+			}
+			if math.Max(f.a.Y, f.b.Y) < miny || math.Min(f.a.Y, f.b.Y) > maxy {
+				continue
+			}
+			for _, p := range segIntersections(e.a, e.b, f.a, f.b) {
+				p = snap.snap(p)
+				cuts[i] = append(cuts[i], p)
+				cuts[j] = append(cuts[j], p)
+			}
+		}
+	}
+
+	var segs []ovSeg
+	index := map[[2]Point]int{}
+	for i, e := range edges {
+		ps := append([]Point{e.a, e.b}, cuts[i]...)
+		dx, dy := e.b.X-e.a.X, e.b.Y-e.a.Y
+		sort.Slice(ps, func(x, y int) bool {
+			return (ps[x].X-e.a.X)*dx+(ps[x].Y-e.a.Y)*dy < (ps[y].X-e.a.X)*dx+(ps[y].Y-e.a.Y)*dy
+		})
+		for k := 1; k < len(ps); k++ {
+			p, q, dir := ps[k-1], ps[k], 1
+			if p == q {
+				continue
+			}
+			if q.X < p.X || (q.X == p.X && q.Y < p.Y) {
+				p, q, dir = q, p, -1
+			}
+			key := [2]Point{p, q}
+			n, ok := index[key]
+			if !ok {
+				n = len(segs)
+				index[key] = n
+				segs = append(segs, ovSeg{a: p, b: q})
+			}
+			segs[n].cnt[e.layer] += dir
+		}
+	}
+	return segs
+}
+
+//===============================================================================
+
+// ovIndex 按条带索引边, 减少射线求交的次数
+type ovIndex struct {
+	lo, step float64
+	strips   [][]int
+}
+
+func newOvIndex(segs []ovSeg, coord func(p Point) float64) *ovIndex {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, s := range segs {
+		lo = math.Min(lo, math.Min(coord(s.a), coord(s.b)))
+		hi = math.Max(hi, math.Max(coord(s.a), coord(s.b)))
+	}
+	n := int(math.Sqrt(float64(len(segs)))) + 1
+	ix := &ovIndex{lo: lo, step: (hi - lo) / float64(n), strips: make([][]int, n)}
+	if !(ix.step > 0) {
+		ix.step = 1
+	}
+	for i, s := range segs {
+		a, b := ix.strip(coord(s.a)), ix.strip(coord(s.b))
+		if a > b {
+			a, b = b, a
+		}
+		for k := a; k <= b; k++ {
+			ix.strips[k] = append(ix.strips[k], i)
+		}
+	}
+	return ix
+}
+
+func (ix *ovIndex) strip(v float64) int {
+	k := int((v - ix.lo) / ix.step)
+	if k < 0 {
+		return 0
+	}
+	if k >= len(ix.strips) {
+		return len(ix.strips) - 1
+	}
+	return k
+}
+
+// ovWinding 第i条边左右两侧在各图层中的环绕数
+func ovWinding(segs []ovSeg, i int, byY, byX *ovIndex) (l, r [2]int) {
+	s := segs[i]
+	m := Point{(s.a.X + s.b.X) / 2, (s.a.Y + s.b.Y) / 2}
+	var w [2]int
+	if math.Abs(s.b.Y-s.a.Y) >= math.Abs(s.b.X-s.a.X) {
+		// 向+x方向的射线, 向上的边计+1
+		for _, j := range byY.strips[byY.strip(m.Y)] {
+			t := segs[j]
+			if j == i || (t.a.Y > m.Y) == (t.b.Y > m.Y) {
+				continue
+			}
+			if t.a.X+(m.Y-t.a.Y)*(t.b.X-t.a.X)/(t.b.Y-t.a.Y) > m.X {
+				up := 1
+				if t.b.Y < t.a.Y {
+					up = -1
+				}
+				for k := range w {
+					w[k] += up * t.cnt[k]
+				}
+			}
+		}
+		if s.b.Y > s.a.Y {
+			// +x 方向为右侧
+			for k := range w {
+				l[k], r[k] = w[k]+s.cnt[k], w[k]
+			}
+		} else {
+			for k := range w {
+				l[k], r[k] = w[k], w[k]-s.cnt[k]
+			}
+		}
+		return
+	}
+	// 向+y方向的射线, 向左的边计+1; 此时 s.a.X < s.b.X, +y 方向为左侧
+	for _, j := range byX.strips[byX.strip(m.X)] {
+		t := segs[j]
+		if j == i || (t.a.X > m.X) == (t.b.X > m.X) {
+			continue
+		}
+		if t.a.Y+(m.X-t.a.X)*(t.b.Y-t.a.Y)/(t.b.X-t.a.X) > m.Y {
+			for k := range w {
+				w[k] -= t.cnt[k]
+			}
+		}
+	}
+	for k := range w {
+		l[k], r[k] = w[k], w[k]-s.cnt[k]
+	}
+	return
+}
+
+// ovBuild 取两侧填充状态不同的边, 连接成多边形
+func ovBuild(segs []ovSeg, fill func(w [2]int) bool) [][][][]float64 {
+	byY := newOvIndex(segs, func(p Point) float64 { return p.Y })
+	byX := newOvIndex(segs, func(p Point) float64 { return p.X })
+	type dirEdge struct {
+		a, b Point
+		used bool
+	}
+	var es []dirEdge
+	out := map[Point][]int{}
+	for i, s := range segs {
+		l, r := ovWinding(segs, i, byY, byX)
+		inL, inR := fill(l), fill(r)
+		if inL == inR {
+			continue
+		}
+		a, b := s.a, s.b
+		if inR {
+			a, b = b, a
+		}
+		out[a] = append(out[a], len(es))
+		es = append(es, dirEdge{a: a, b: b})
+	}
+
+	var shells, holes [][]Point
+	for i := range es {
+		if es[i].used {
+			continue
+		}
+		var ring []Point
+		for e := i; e >= 0 && !es[e].used; {
+			es[e].used = true
+			ring = append(ring, es[e].a)
+			// 入边反方向顺时针的第一条出边
+			u, v := es[e].a, es[e].b
+			back := math.Atan2(u.Y-v.Y, u.X-v.X)
+			next, best := -1, math.Inf(1)
+			for _, c := range out[v] {
+				d := back - math.Atan2(es[c].b.Y-v.Y, es[c].b.X-v.X)
+				for d <= 0 {
+					d += 2 * math.Pi
+				}
+				if d < best {
+					next, best = c, d
+				}
+			}
+			e = next
+		}
+		ring = ovClean(ring)
+		if len(ring) < 3 {
+			continue
+		}
+		if a := ovArea(ring); a > 0 {
+			shells = append(shells, ring)
+		} else if a < 0 {
+			holes = append(holes, ring)
+		}
+	}
+
+	polys := make([][][][]float64, len(shells))
+	areas := make([]float64, len(shells))
+	for i, s := range shells {
+		polys[i] = [][][]float64{ovCoords(s)}
+		areas[i] = ovArea(s)
+	}
+	for _, h := range holes {
+		// 内环第一条边的中点必在所属外环内部, 取包含它的最小外环
+		p := Point{(h[0].X + h[1].X) / 2, (h[0].Y + h[1].Y) / 2}
+		owner := -1
+		for i := range shells {
+			if (owner < 0 || areas[i] < areas[owner]) && locateRings(polys[i][:1], p) == locInterior {
+				owner = i
+			}
+		}
+		if owner >= 0 {
+			polys[owner] = append(polys[owner], ovCoords(h))
+		}
+	}
+	return polys
+}
+
+// ovClean 去掉共线的点
+func ovClean(ring []Point) []Point {
+	for changed := true; changed && len(ring) >= 3; {
+		changed = false
+		for i := 0; i < len(ring) && len(ring) >= 3; i++ {
+			prev, next := ring[(i+len(ring)-1)%len(ring)], ring[(i+1)%len(ring)]
+			if onSegment(ring[i], prev, next) {
+				ring = append(ring[:i], ring[i+1:]...)
+				changed = true
+			}
+		}
+	}
+	return ring
+}
+
+// ovArea 环的有向面积
+func ovArea(ring []Point) float64 {
+	s := 0.0
+	for i, n := 0, len(ring); i < n; i++ {
+		a, b := ring[i], ring[(i+1)%n]
+		s += a.X*b.Y - b.X*a.Y
+	}
+	return s / 2
+}
+
+// ovCoords 环转为闭合的坐标
+func ovCoords(ring []Point) [][]float64 {
+	cs := make([][]float64, 0, len(ring)+1)
+	for _, p := range ring {
+		cs = append(cs, []float64{p.X, p.Y})
+	}
+	return append(cs, []float64{ring[0].X, ring[0].Y})
+}
@@ -0,0 +1,213 @@
+package xutil
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/***
+路径编码
+https://developers.google.com/maps/documentation/utilities/polylinealgorithm  Google Encoded Polyline
+	坐标按 [lon, lat] 传入, 编码顺序同 Google 为 lat,lon; precision 为小数位数, Google 为5, OSRM/Valhalla 为6
+百度 geo 字符串: "类型|边界|几何", 坐标为百度墨卡托, 解析后为BD09经纬度
+	1|x,y;x,y|x,y;               点
+	2|x,y;x,y|x,y,x,y,...;       线
+	4|x,y;x,y|1-x,y,x,y,...;...  多段线或面, 各段都闭合时为面
+高德 polyline: "lng,lat;lng,lat|lng,lat;..." 多段用 | 分隔
+***/
+
+// EncodePolyline 编码坐标序列, precision 小于1时取5
+func EncodePolyline(coords [][]float64, precision int) string {
+	if precision < 1 {
+		precision = 5
+	}
+	f := math.Pow10(precision)
+	var sb strings.Builder
+	var plat, plon int64
+	for _, c := range coords {
+		lat, lon := int64(math.Round(c[1]*f)), int64(math.Round(c[0]*f))
+		polylineWrite(&sb, lat-plat)
+		polylineWrite(&sb, lon-plon)
+		plat, plon = lat, lon
+	}
+	return sb.String()
+}
+
+func polylineWrite(sb *strings.Builder, v int64) {
+	u := uint64(v) << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		sb.WriteByte(byte(0x20|u&0x1f) + 63)
+		u >>= 5
+	}
+	sb.WriteByte(byte(u) + 63)
+}
+
+// DecodePolyline 解码为 [lon, lat] 坐标序列, precision 小于1时取5
+func DecodePolyline(s string, precision int) ([][]float64, error) {
+	if precision < 1 {
+		precision = 5
+	}
+	f := math.Pow10(precision)
+	var coords [][]float64
+	var lat, lon int64
+	for i := 0; i < len(s); {
+		var d [2]int64
+		for k := range d {
+			var u uint64
+			for shift := uint(0); ; shift += 5 {
+				if i >= len(s) {
+					return nil, errors.New("polyline解析失败: 意外结束")
+				}
+				if s[i] < 63 || s[i] > 126 || shift > 60 {
+					return nil, fmt.Errorf("polyline解析失败: 位置%d 字符%q", i, s[i])
+				}
+				b := uint64(s[i] - 63)
+				i++
+				u |= (b & 0x1f) << shift
+				if b < 0x20 {
+					break
+				}
+			}
+			if u&1 != 0 {
+				d[k] = ^int64(u >> 1)
+			} else {
+				d[k] = int64(u >> 1)
+			}
+		}
+		lat, lon = lat+d[0], lon+d[1]
+		coords = append(coords, []float64{float64(lon) / f, float64(lat) / f})
+	}
+	return coords, nil
+}
+
+//===============================================================================
+
+// FromBmapGeo 解析百度 geo 字符串为BD09经纬度的 Geo
+func FromBmapGeo(s string) (g Geo, err error) {
+	parts := strings.Split(strings.TrimSpace(s), "|")
+	if len(parts) < 3 {
+		return g, fmt.Errorf("百度geo解析失败: %s", s)
+	}
+	var segs [][][]float64
+	for _, seg := range strings.Split(parts[2], ";") {
+		if seg = strings.TrimSpace(seg); seg == "" {
+			continue
+		}
+		// 去掉 "1-" 形式的分段前缀
+		if i := strings.IndexByte(seg, '-'); i > 0 && !strings.ContainsAny(seg[:i], ",.") {
+			seg = seg[i+1:]
+		}
+		cs, err := parseBmapCoords(seg)
+		if err != nil {
+			return g, fmt.Errorf("百度geo解析失败: %s %v", s, err)
+		}
+		segs = append(segs, cs)
+	}
+	if len(segs) == 0 {
+		return g, fmt.Errorf("百度geo解析失败: 没有坐标 %s", s)
+	}
+
+	if parts[0] == "1" {
+		var pts [][]float64
+		for _, cs := range segs {
+			pts = append(pts, cs...)
+		}
+		g = Geo{Type: "MultiPoint", Coords: [][][][]float64{{pts}}}
+		if len(pts) == 1 {
+			g.Type = "Point"
+		}
+		return g, nil
+	}
+	closed := parts[0] != "2"
+	for _, cs := range segs {
+		closed = closed && len(cs) >= 4 && pointEqual(coordPoint(cs[0]), coordPoint(cs[len(cs)-1]))
+	}
+	if closed {
+		return ringsGeo(segs), nil
+	}
+	g = Geo{Type: "MultiLineString", Coords: [][][][]float64{segs}}
+	if len(segs) == 1 {
+		g.Type = "LineString"
+	}
+	return g, nil
+}
+
+// parseBmapCoords 解析 "x,y,x,y" 墨卡托坐标为BD09经纬度
+func parseBmapCoords(s string) ([][]float64, error) {
+	vs := strings.Split(strings.Trim(s, ","), ",")
+	if len(vs)%2 != 0 {
+		return nil, fmt.Errorf("坐标个数错误 %d", len(vs))
+	}
+	cs := make([][]float64, 0, len(vs)/2)
+	for i := 0; i < len(vs); i += 2 {
+		x, err := strconv.ParseFloat(strings.TrimSpace(vs[i]), 64)
+		if err != nil {
+			return nil, err
+		}
+		y, err := strconv.ParseFloat(strings.TrimSpace(vs[i+1]), 64)
+		if err != nil {
+			return nil, err
+		}
+		lng, lat := MercatorToBd09(x, y)
+		cs = append(cs, []float64{lng, lat})
+	}
+	return cs, nil
+}
+
+// ringsGeo 闭合环组成面, 落在前面外环内的环作为其内环
+func ringsGeo(rings [][][]float64) Geo {
+	var polys [][][][]float64
+	for _, ring := range rings {
+		owner := -1
+		for i, rs := range polys {
+			if locateRings(rs[:1], coordPoint(ring[0])) == locInterior {
+				owner = i
+			}
+		}
+		if owner >= 0 {
+			polys[owner] = append(polys[owner], ring)
+		} else {
+			polys = append(polys, [][][]float64{ring})
+		}
+	}
+	return polysGeo(polys)
+}
+
+// FromAmapPolyline 解析高德 polyline 为 LineString 或 MultiLineString
+func FromAmapPolyline(s string) (g Geo, err error) {
+	var lines [][][]float64
+	for _, part := range strings.Split(strings.TrimSpace(s), "|") {
+		var line [][]float64
+		for _, p := range strings.Split(part, ";") {
+			if p = strings.TrimSpace(p); p == "" {
+				continue
+			}
+			lnglat := strings.Split(p, ",")
+			if len(lnglat) != 2 {
+				return g, fmt.Errorf("高德polyline解析失败: %s", p)
+			}
+			lng, err1 := strconv.ParseFloat(lnglat[0], 64)
+			lat, err2 := strconv.ParseFloat(lnglat[1], 64)
+			if err1 != nil || err2 != nil {
+				return g, fmt.Errorf("高德polyline解析失败: %s", p)
+			}
+			line = append(line, []float64{lng, lat})
+		}
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	switch len(lines) {
+	case 0:
+		return Geo{Type: "LineString"}, nil
+	case 1:
+		return Geo{Type: "LineString", Coords: [][][][]float64{lines}}, nil
+	}
+	return Geo{Type: "MultiLineString", Coords: [][][][]float64{lines}}, nil
+}
@@ -0,0 +1,294 @@
+package xutil
+
+import (
+	"math"
+	"sort"
+)
+
+/***
+空间关系判断(平面, 经纬度直接按x,y计算)
+https://en.wikipedia.org/wiki/DE-9IM
+https://wrf.ecse.rpi.edu/Research/Short_Notes/pnpoly.html  射线法判断点在多边形内
+	点位于线/面的边界上时按边界处理, 容差 _geoEps
+***/
+
+const _geoEps = 1e-10 // 坐标容差(度), 约0.01毫米
+
+// 点相对几何对象的位置
+const (
+	locExterior = iota
+	locBoundary
+	locInterior
+)
+
+func cross(o, a, b Point) float64 {
+	return (a.X-o.X)*(b.Y-o.Y) - (a.Y-o.Y)*(b.X-o.X)
+}
+
+func pointEqual(a, b Point) bool {
+	return math.Abs(a.X-b.X) <= _geoEps && math.Abs(a.Y-b.Y) <= _geoEps
+}
+
+// pointSegDist2 点到线段距离的平方, 返回垂足
+func pointSegDist2(p, a, b Point) (float64, Point) {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	t := 0.0
+	if l2 := dx*dx + dy*dy; l2 > 0 {
+		t = math.Max(0, math.Min(1, ((p.X-a.X)*dx+(p.Y-a.Y)*dy)/l2))
+	}
+	q := Point{a.X + t*dx, a.Y + t*dy}
+	return (p.X-q.X)*(p.X-q.X) + (p.Y-q.Y)*(p.Y-q.Y), q
+}
+
+// onSegment 点是否在线段上
+func onSegment(p, a, b Point) bool {
+	if p.X < math.Min(a.X, b.X)-_geoEps || p.X > math.Max(a.X, b.X)+_geoEps ||
+		p.Y < math.Min(a.Y, b.Y)-_geoEps || p.Y > math.Max(a.Y, b.Y)+_geoEps {
+		return false
+	}
+	d2, _ := pointSegDist2(p, a, b)
+	return d2 <= _geoEps*_geoEps
+}
+
+// segIntersections 线段ab与cd的交点, 共线重叠时返回重叠部分端点
+func segIntersections(a, b, c, d Point) []Point {
+	r := Point{b.X - a.X, b.Y - a.Y}
+	s := Point{d.X - c.X, d.Y - c.Y}
+	den := r.X*s.Y - r.Y*s.X
+	if math.Abs(den) <= _geoEps*_geoEps {
+		var ps []Point
+		for _, p := range []Point{c, d} {
+			if onSegment(p, a, b) {
+				ps = append(ps, p)
+			}
+		}
+		for _, p := range []Point{a, b} {
+			if onSegment(p, c, d) {
+				ps = append(ps, p)
+			}
+		}
+		return ps
+	}
+	t := ((c.X-a.X)*s.Y - (c.Y-a.Y)*s.X) / den
+	u := ((c.X-a.X)*r.Y - (c.Y-a.Y)*r.X) / den
+	if t < -_geoEps || t > 1+_geoEps || u < -_geoEps || u > 1+_geoEps {
+		return nil
+	}
+	return []Point{{a.X + t*r.X, a.Y + t*r.Y}}
+}
+
+// coordPoint 坐标转Point
+func coordPoint(c []float64) Point {
+	return Point{c[0], c[1]}
+}
+
+//===============================================================================
+
+// locateRings 点相对多边形(外环+内环)的位置, 射线法
+func locateRings(rings [][][]float64, p Point) int {
+	inside := false
+	for _, ring := range rings {
+		n := len(ring)
+		for i, j := 0, n-1; i < n; j, i = i, i+1 {
+			a, b := coordPoint(ring[i]), coordPoint(ring[j])
+			if onSegment(p, a, b) {
+				return locBoundary
+			}
+			if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+				inside = !inside
+			}
+		}
+	}
+	if inside {
+		return locInterior
+	}
+	return locExterior
+}
+
+// locate 点相对几何对象的位置: 内部/边界/外部
+func (g Geo) locate(p Point) int {
+	loc := locExterior
+	for _, sub := range g.Geoms {
+		if l := sub.locate(p); l > loc {
+			loc = l
+		}
+	}
+	if loc == locInterior || g.IsEmpty() {
+		return loc
+	}
+
+	switch g.Type {
+	case "Point", "MultiPoint":
+		for _, c := range g.Coords[0][0] {
+			if len(c) >= 2 && pointEqual(p, coordPoint(c)) {
+				return locInterior
+			}
+		}
+	case "LineString", "MultiLineString":
+		// mod-2规则: 非闭合线的端点出现奇数次为边界
+		ends, on := 0, false
+		for _, line := range g.Coords[0] {
+			n := len(line)
+			if n == 0 {
+				continue
+			}
+			first, last := coordPoint(line[0]), coordPoint(line[n-1])
+			if !pointEqual(first, last) {
+				if pointEqual(p, first) {
+					ends++
+				}
+				if pointEqual(p, last) {
+					ends++
+				}
+			}
+			for i := 1; i < n && !on; i++ {
+				on = onSegment(p, coordPoint(line[i-1]), coordPoint(line[i]))
+			}
+		}
+		if ends%2 == 1 {
+			loc = locBoundary
+		} else if on {
+			return locInterior
+		}
+	case "Polygon", "MultiPolygon":
+		for _, rings := range g.Coords {
+			switch locateRings(rings, p) {
+			case locInterior:
+				return locInterior
+			case locBoundary:
+				loc = locBoundary
+			}
+		}
+	}
+	return loc
+}
+
+// Contains 点是否在几何对象内部(不含边界)
+func (g Geo) Contains(p Point) bool {
+	return g.locate(p) == locInterior
+}
+
+// Covers 点是否在几何对象内部或边界上
+func (g Geo) Covers(p Point) bool {
+	return g.locate(p) != locExterior
+}
+
+//===============================================================================
+
+// boxIntersects 边界框是否相交
+func boxIntersects(a, b []float64) bool {
+	return a != nil && b != nil && a[0] <= b[2]+_geoEps && b[0] <= a[2]+_geoEps && a[1] <= b[3]+_geoEps && b[1] <= a[3]+_geoEps
+}
+
+// hasArea 是否包含面
+func (g Geo) hasArea() bool {
+	for _, sub := range g.Geoms {
+		if sub.hasArea() {
+			return true
+		}
+	}
+	return (g.Type == "Polygon" || g.Type == "MultiPolygon") && !g.IsEmpty()
+}
+
+// Intersects 是否相交(含接触)
+func (g Geo) Intersects(o Geo) bool {
+	if !boxIntersects(g.Box(), o.Box()) {
+		return false
+	}
+	for _, p := range g.Points() {
+		if o.locate(p) != locExterior {
+			return true
+		}
+	}
+	for _, p := range o.Points() {
+		if g.locate(p) != locExterior {
+			return true
+		}
+	}
+	ol := o.Lines()
+	for _, l := range g.Lines() {
+		for _, m := range ol {
+			if len(segIntersections(l.P1, l.P2, m.P1, m.P2)) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Disjoint 是否不相交
+func (g Geo) Disjoint(o Geo) bool {
+	return !g.Intersects(o)
+}
+
+// samples 几何对象上的采样点: 顶点、与o的交点、交点间线段中点, 面对象另取边两侧的偏移点
+func (g Geo) samples(o Geo) []Point {
+	ps := g.Points()
+	ol := o.Lines()
+	area := g.hasArea()
+	for _, l := range g.Lines() {
+		cut := []Point{l.P1, l.P2}
+		for _, m := range ol {
+			cut = append(cut, segIntersections(l.P1, l.P2, m.P1, m.P2)...)
+		}
+		dx, dy := l.P2.X-l.P1.X, l.P2.Y-l.P1.Y
+		sort.Slice(cut, func(i, j int) bool {
+			return (cut[i].X-l.P1.X)*dx+(cut[i].Y-l.P1.Y)*dy < (cut[j].X-l.P1.X)*dx+(cut[j].Y-l.P1.Y)*dy
+		})
+		for i := 1; i < len(cut); i++ {
+			a, b := cut[i-1], cut[i]
+			if pointEqual(a, b) {
+				continue
+			}
+			mid := Point{(a.X + b.X) / 2, (a.Y + b.Y) / 2}
+			ps = append(ps, b, mid)
+			if area {
+				// 偏移量取子线段长度的千分之一
+				nx, ny := -(b.Y-a.Y)*1e-3, (b.X-a.X)*1e-3
+				ps = append(ps, Point{mid.X + nx, mid.Y + ny}, Point{mid.X - nx, mid.Y - ny})
+			}
+		}
+	}
+	return ps
+}
+
+// interiorsIntersect 内部是否相交
+func (g Geo) interiorsIntersect(o Geo) bool {
+	for _, p := range g.samples(o) {
+		if g.locate(p) == locInterior && o.locate(p) == locInterior {
+			return true
+		}
+	}
+	for _, p := range o.samples(g) {
+		if o.locate(p) == locInterior && g.locate(p) == locInterior {
+			return true
+		}
+	}
+	return false
+}
+
+// Within 是否在o内部: g不在o外部, 且内部相交
+func (g Geo) Within(o Geo) bool {
+	if g.IsEmpty() || !boxIntersects(g.Box(), o.Box()) {
+		return false
+	}
+	for _, p := range g.samples(o) {
+		if g.locate(p) != locExterior && o.locate(p) == locExterior {
+			return false
+		}
+	}
+	if g.hasArea() {
+		// o的边界落在g的内部时, g必然包含o的外部
+		for _, p := range o.samples(g) {
+			if o.locate(p) == locBoundary && g.locate(p) == locInterior {
+				return false
+			}
+		}
+	}
+	return g.interiorsIntersect(o)
+}
+
+// Touches 是否接触: 相交但内部不相交
+func (g Geo) Touches(o Geo) bool {
+	return g.Intersects(o) && !g.interiorsIntersect(o)
+}
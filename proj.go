@@ -0,0 +1,193 @@
+package xutil
+
+import (
+	"math"
+)
+
+/***
+投影: 高斯-克吕格(CGCS2000) UTM(WGS84) Web墨卡托(EPSG:3857)
+https://arxiv.org/abs/1002.1417  Karney, Transverse Mercator with an accuracy of a few nanometers
+https://www.movable-type.co.uk/scripts/latlong-utm-mgrs.html
+	横轴墨卡托按 Krüger 级数(6阶)计算, 带内精度优于1mm
+	高斯-克吕格: 6度带带号 floor(lon/6)+1, 中央经线 6*带号-3; 3度带带号 round(lon/3), 中央经线 3*带号
+		比例因子1, 东偏 500000, 带号前缀为东坐标加 带号*1000000
+	UTM: 带号 floor((lon+180)/6)+1, 比例因子0.9996, 东偏 500000, 南半球北偏 10000000
+	坐标按 x 东坐标、y 北坐标返回, 与测量习惯的 X北 Y东 相反
+***/
+
+// Ellipsoid 椭球, A 长半轴(米), F 扁率
+type Ellipsoid struct {
+	A, F float64
+}
+
+// 常用椭球
+var (
+	EllipsoidWGS84    = Ellipsoid{A: 6378137, F: 1 / 298.257223563}
+	EllipsoidCGCS2000 = Ellipsoid{A: 6378137, F: 1 / 298.257222101}
+)
+
+const _webMercatorR = 6378137.0
+
+var (
+	_tmWGS84    = EllipsoidWGS84.tm()
+	_tmCGCS2000 = EllipsoidCGCS2000.tm()
+)
+
+// tmSeries 横轴墨卡托的 Krüger 级数系数
+type tmSeries struct {
+	e, a        float64 // 第一偏心率, 子午圈的矩形化半径
+	alpha, beta [6]float64
+}
+
+func (e Ellipsoid) tm() tmSeries {
+	n := e.F / (2 - e.F)
+	n2, n3, n4, n5, n6 := n*n, n*n*n, n*n*n*n, n*n*n*n*n, n*n*n*n*n*n
+	return tmSeries{
+		e: math.Sqrt(e.F * (2 - e.F)),
+		a: e.A / (1 + n) * (1 + n2/4 + n4/64 + n6/256),
+		alpha: [6]float64{
+			n/2 - 2*n2/3 + 5*n3/16 + 41*n4/180 - 127*n5/288 + 7891*n6/37800,
+			13*n2/48 - 3*n3/5 + 557*n4/1440 + 281*n5/630 - 1983433*n6/1935360,
+			61*n3/240 - 103*n4/140 + 15061*n5/26880 + 167603*n6/181440,
+			49561*n4/161280 - 179*n5/168 + 6601661*n6/7257600,
+			34729*n5/80640 - 3418889*n6/1995840,
+			212378941 * n6 / 319334400,
+		},
+		beta: [6]float64{
+			n/2 - 2*n2/3 + 37*n3/96 - n4/360 - 81*n5/512 + 96199*n6/604800,
+			n2/48 + n3/15 - 437*n4/1440 + 46*n5/105 - 1118711*n6/3870720,
+			17*n3/480 - 37*n4/840 - 209*n5/4480 + 5569*n6/90720,
+			4397*n4/161280 - 11*n5/504 - 830251*n6/7257600,
+			4583*n5/161280 - 108847*n6/3991680,
+			20648693 * n6 / 638668800,
+		},
+	}
+}
+
+// forward 经纬度转横轴墨卡托, 不含东偏、北偏
+func (s tmSeries) forward(lon, lat, lon0, k0 float64) (x, y float64) {
+	φ, λ := Radians(lat), Radians(lon-lon0)
+	τ := math.Tan(φ)
+	σ := math.Sinh(s.e * math.Atanh(s.e*τ/math.Sqrt(1+τ*τ)))
+	τp := τ*math.Sqrt(1+σ*σ) - σ*math.Sqrt(1+τ*τ)
+	ξp := math.Atan2(τp, math.Cos(λ))
+	ηp := math.Asinh(math.Sin(λ) / math.Sqrt(τp*τp+math.Cos(λ)*math.Cos(λ)))
+	ξ, η := ξp, ηp
+	for j, a := range s.alpha {
+		k := 2 * float64(j+1)
+		ξ += a * math.Sin(k*ξp) * math.Cosh(k*ηp)
+		η += a * math.Cos(k*ξp) * math.Sinh(k*ηp)
+	}
+	return k0 * s.a * η, k0 * s.a * ξ
+}
+
+// inverse 横轴墨卡托转经纬度, x y 不含东偏、北偏
+func (s tmSeries) inverse(x, y, lon0, k0 float64) (lon, lat float64) {
+	ξ, η := y/(k0*s.a), x/(k0*s.a)
+	ξp, ηp := ξ, η
+	for j, b := range s.beta {
+		k := 2 * float64(j+1)
+		ξp -= b * math.Sin(k*ξ) * math.Cosh(k*η)
+		ηp -= b * math.Cos(k*ξ) * math.Sinh(k*η)
+	}
+	sinhηp, sinξp, cosξp := math.Sinh(ηp), math.Sin(ξp), math.Cos(ξp)
+	τp := sinξp / math.Sqrt(sinhηp*sinhηp+cosξp*cosξp)
+
+	// 牛顿迭代求 τ = tan(φ)
+	e2 := s.e * s.e
+	τ := τp
+	for i := 0; i < 10; i++ {
+		σ := math.Sinh(s.e * math.Atanh(s.e*τ/math.Sqrt(1+τ*τ)))
+		τi := τ*math.Sqrt(1+σ*σ) - σ*math.Sqrt(1+τ*τ)
+		δ := (τp - τi) / math.Sqrt(1+τi*τi) * (1 + (1-e2)*τ*τ) / ((1 - e2) * math.Sqrt(1+τ*τ))
+		τ += δ
+		if math.Abs(δ) < 1e-12 {
+			break
+		}
+	}
+	return lon0 + Degrees(math.Atan2(sinhηp, cosξp)), Degrees(math.Atan(τ))
+}
+
+//===============================================================================
+
+// GKZone 高斯-克吕格投影带号及中央经线, width 为3或6(默认)
+func GKZone(lon float64, width int) (zone int, cm float64) {
+	if width == 3 {
+		zone = int(math.Floor(lon/3 + 0.5))
+		return zone, float64(zone * 3)
+	}
+	zone = int(math.Floor(lon/6)) + 1
+	return zone, float64(zone*6 - 3)
+}
+
+// LonLat2GK CGCS2000经纬度转高斯-克吕格平面坐标, cm 为中央经线, 东坐标不带带号
+func LonLat2GK(lon, lat, cm float64) (x, y float64) {
+	x, y = _tmCGCS2000.forward(lon, lat, cm, 1)
+	return x + 500000, y
+}
+
+// GK2LonLat 高斯-克吕格平面坐标转CGCS2000经纬度, cm 为中央经线, 东坐标不带带号
+func GK2LonLat(x, y, cm float64) (lon, lat float64) {
+	return _tmCGCS2000.inverse(x-500000, y, cm, 1)
+}
+
+// Cgcs2GK CGCS2000经纬度转高斯-克吕格平面坐标, 按经度确定带号, 东坐标带带号前缀
+func Cgcs2GK(lon, lat float64, width int) (x, y float64) {
+	zone, cm := GKZone(lon, width)
+	x, y = LonLat2GK(lon, lat, cm)
+	return x + float64(zone)*1e6, y
+}
+
+// GK2Cgcs 带带号前缀的高斯-克吕格平面坐标转CGCS2000经纬度, width 为3或6(默认)
+func GK2Cgcs(x, y float64, width int) (lon, lat float64) {
+	zone := math.Floor(x / 1e6)
+	cm := zone*6 - 3
+	if width == 3 {
+		cm = zone * 3
+	}
+	return GK2LonLat(x-zone*1e6, y, cm)
+}
+
+//===============================================================================
+
+// UTMZone UTM投影带号
+func UTMZone(lon float64) int {
+	return clampInt(int(math.Floor((lon+180)/6))+1, 1, 60)
+}
+
+// Wgs2UTM WGS84经纬度转UTM平面坐标, 按经纬度确定带号及南北半球
+func Wgs2UTM(lon, lat float64) (x, y float64, zone int, north bool) {
+	zone, north = UTMZone(lon), lat >= 0
+	x, y = Wgs2UTMZone(lon, lat, zone, north)
+	return
+}
+
+// Wgs2UTMZone WGS84经纬度转指定带的UTM平面坐标
+func Wgs2UTMZone(lon, lat float64, zone int, north bool) (x, y float64) {
+	x, y = _tmWGS84.forward(lon, lat, float64(zone*6-183), 0.9996)
+	if !north {
+		y += 10000000
+	}
+	return x + 500000, y
+}
+
+// UTM2Wgs UTM平面坐标转WGS84经纬度
+func UTM2Wgs(x, y float64, zone int, north bool) (lon, lat float64) {
+	if !north {
+		y -= 10000000
+	}
+	return _tmWGS84.inverse(x-500000, y, float64(zone*6-183), 0.9996)
+}
+
+//===============================================================================
+
+// Wgs2WebMercator WGS84经纬度转Web墨卡托(EPSG:3857), 纬度限制在 ±85.0511
+func Wgs2WebMercator(lon, lat float64) (x, y float64) {
+	φ := Radians(math.Max(-_mercatorMaxLat, math.Min(_mercatorMaxLat, lat)))
+	return _webMercatorR * Radians(lon), _webMercatorR * math.Log(math.Tan(math.Pi/4+φ/2))
+}
+
+// WebMercator2Wgs Web墨卡托(EPSG:3857)转WGS84经纬度
+func WebMercator2Wgs(x, y float64) (lon, lat float64) {
+	return Degrees(x / _webMercatorR), Degrees(2*math.Atan(math.Exp(y/_webMercatorR)) - math.Pi/2)
+}
@@ -0,0 +1,310 @@
+package xutil
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg" // 本地瓦片支持jpg
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/***
+静态地图, 将 Geo 按Web墨卡托绘制为 PNG
+	视口: 取所有对象 Box() 的中心, 在 MaxZoom 以内选能容纳全部对象的最大层级
+	底图: TilePath 为本地瓦片路径模板, 如 "tiles/{z}/{x}/{y}.png", 瓦片编号同 Wgs2Tile, 缺失的瓦片跳过
+	填充按奇偶规则, 每像素4条子扫描线抗锯齿; 线按线段胶囊形描边
+***/
+
+// Style 绘制样式, 颜色为 nil 时不绘制
+type Style struct {
+	Fill        color.Color // 面、点的填充色
+	Stroke      color.Color // 线、面边界、点边框的颜色
+	StrokeWidth float64     // 线宽(像素), 默认1
+	PointRadius float64     // 点半径(像素), 默认3
+}
+
+// StaticMap 静态地图
+type StaticMap struct {
+	Width, Height int
+	Padding       int         // 对象与图片边缘的最小距离(像素)
+	MaxZoom       int         // 最大层级, 默认18
+	Background    color.Color // 背景色, 默认白色
+	TilePath      string      // 本地瓦片路径模板, 为空时不绘制底图
+	geos          []Geo
+	styles        []Style
+}
+
+// NewStaticMap 创建静态地图
+func NewStaticMap(width, height int) *StaticMap {
+	return &StaticMap{Width: width, Height: height, Padding: 10, MaxZoom: 18, Background: color.White}
+}
+
+// Add 添加要绘制的几何对象, 按添加顺序绘制
+func (m *StaticMap) Add(g Geo, style Style) {
+	m.geos = append(m.geos, g)
+	m.styles = append(m.styles, style)
+}
+
+// mercatorPixel 经纬度转 z 级全球像素坐标(瓦片边长256像素), 公式同 Wgs2Tile, 纬度限制在Web墨卡托范围内
+func mercatorPixel(lon, lat float64, z int) (float64, float64) {
+	n := 256 * math.Exp2(float64(z))
+	φ := Radians(math.Max(-_mercatorMaxLat, math.Min(_mercatorMaxLat, lat)))
+	return (lon + 180) / 360 * n, (1 - math.Log(math.Tan(φ)+1/math.Cos(φ))/math.Pi) / 2 * n
+}
+
+// viewport 层级及图片左上角的全球像素坐标
+func (m *StaticMap) viewport() (z int, ox, oy float64) {
+	var b []float64
+	for _, g := range m.geos {
+		if gb := g.Box(); gb != nil {
+			if b == nil {
+				b = gb
+			} else {
+				b = []float64{math.Min(b[0], gb[0]), math.Min(b[1], gb[1]), math.Max(b[2], gb[2]), math.Max(b[3], gb[3])}
+			}
+		}
+	}
+	if b == nil {
+		b = []float64{0, 0, 0, 0}
+	}
+	maxZ := m.MaxZoom
+	if maxZ <= 0 {
+		maxZ = 18
+	}
+	w, h := float64(m.Width-2*m.Padding), float64(m.Height-2*m.Padding)
+	for z = maxZ; z > 0; z-- {
+		x0, y0 := mercatorPixel(b[0], b[3], z)
+		x1, y1 := mercatorPixel(b[2], b[1], z)
+		if x1-x0 <= w && y1-y0 <= h {
+			break
+		}
+	}
+	x0, y0 := mercatorPixel(b[0], b[3], z)
+	x1, y1 := mercatorPixel(b[2], b[1], z)
+	return z, (x0+x1)/2 - float64(m.Width)/2, (y0+y1)/2 - float64(m.Height)/2
+}
+
+// Render 绘制为图片
+func (m *StaticMap) Render() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, m.Width, m.Height))
+	bg := m.Background
+	if bg == nil {
+		bg = color.White
+	}
+	draw.Draw(img, img.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	z, ox, oy := m.viewport()
+	if m.TilePath != "" {
+		m.drawTiles(img, z, ox, oy)
+	}
+	toPixel := func(c []float64) Point {
+		x, y := mercatorPixel(c[0], c[1], z)
+		return Point{x - ox, y - oy}
+	}
+	for i, g := range m.geos {
+		drawGeo(img, g, m.styles[i], toPixel)
+	}
+	return img
+}
+
+// WritePNG 绘制并输出PNG
+func (m *StaticMap) WritePNG(w io.Writer) error {
+	return png.Encode(w, m.Render())
+}
+
+// SavePNG 绘制并保存为PNG文件
+func (m *StaticMap) SavePNG(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err = m.WritePNG(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// drawTiles 绘制视口内的本地瓦片
+func (m *StaticMap) drawTiles(img *image.RGBA, z int, ox, oy float64) {
+	// 视口左上、右下角像素所在的 z+8 级"瓦片"即该像素
+	px0, py0 := int(math.Floor(ox)), int(math.Floor(oy))
+	px1, py1 := int(math.Ceil(ox))+m.Width, int(math.Ceil(oy))+m.Height
+	maxy, minx := Tile2Wgs(px0, py0, z+8)
+	miny, maxx := Tile2Wgs(px1, py1, z+8)
+	for _, t := range TileXYZ.Cover(boxGeo([]float64{minx, miny, maxx, maxy}), z) {
+		r := strings.NewReplacer("{z}", strconv.Itoa(t.Z), "{x}", strconv.Itoa(t.X), "{y}", strconv.Itoa(t.Y))
+		tile, err := loadImage(r.Replace(m.TilePath))
+		if err != nil {
+			continue
+		}
+		p := image.Pt(int(math.Round(float64(t.X*256)-ox)), int(math.Round(float64(t.Y*256)-oy)))
+		draw.Draw(img, image.Rectangle{p, p.Add(image.Pt(256, 256))}, tile, tile.Bounds().Min, draw.Over)
+	}
+}
+
+func loadImage(filename string) (image.Image, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+//===============================================================================
+
+// drawGeo 按样式绘制几何对象
+func drawGeo(img *image.RGBA, g Geo, s Style, toPixel func(c []float64) Point) {
+	width, radius := s.StrokeWidth, s.PointRadius
+	if width <= 0 {
+		width = 1
+	}
+	if radius <= 0 {
+		radius = 3
+	}
+	pixels := func(b [][]float64) []Point {
+		ps := make([]Point, len(b))
+		for i, c := range b {
+			ps[i] = toPixel(c)
+		}
+		return ps
+	}
+	stroke := func(lines [][]Point) {
+		if s.Stroke == nil {
+			return
+		}
+		var shapes [][][]Point
+		for _, ps := range lines {
+			for i := 1; i < len(ps); i++ {
+				shapes = append(shapes, [][]Point{ringPoints(capsuleRing(ps[i-1], ps[i], width/2, 2))})
+			}
+			if len(ps) == 1 {
+				shapes = append(shapes, [][]Point{ringPoints(circleRing(ps[0], width/2, 2))})
+			}
+		}
+		fillShapes(img, shapes, s.Stroke)
+	}
+
+	g.eachPart(func(c []float64) {
+		circle := [][]Point{ringPoints(circleRing(toPixel(c), radius, 4))}
+		if s.Fill != nil {
+			fillShapes(img, [][][]Point{circle}, s.Fill)
+		}
+		stroke(circle)
+	}, func(b [][]float64) {
+		stroke([][]Point{pixels(b)})
+	}, func(rings [][][]float64) {
+		var ps [][]Point
+		for _, ring := range rings {
+			ps = append(ps, pixels(ring))
+		}
+		if s.Fill != nil {
+			fillShapes(img, [][][]Point{ps}, s.Fill)
+		}
+		stroke(ps)
+	})
+}
+
+func ringPoints(ring [][]float64) []Point {
+	ps := make([]Point, len(ring))
+	for i, c := range ring {
+		ps[i] = coordPoint(c)
+	}
+	return ps
+}
+
+// fillShapes 填充多个形状, 重叠部分不重复混合颜色
+func fillShapes(img *image.RGBA, shapes [][][]Point, c color.Color) {
+	var r image.Rectangle
+	for _, rings := range shapes {
+		r = r.Union(pointsRect(rings))
+	}
+	if r = r.Intersect(img.Bounds()); r.Empty() {
+		return
+	}
+	mask := image.NewAlpha(r)
+	for _, rings := range shapes {
+		fillRings(mask, rings)
+	}
+	draw.DrawMask(img, r, image.NewUniform(c), image.Point{}, mask, r.Min, draw.Over)
+}
+
+// fillRings 按奇偶规则把环覆盖的像素写入遮罩, 取已有值与覆盖率的较大值
+func fillRings(mask *image.Alpha, rings [][]Point) {
+	const sub = 4
+	// 只处理环的边界框内的像素
+	b := pointsRect(rings).Intersect(mask.Bounds())
+	if b.Empty() {
+		return
+	}
+	cov := make([]float64, b.Dx())
+	var xs []float64
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for i := range cov {
+			cov[i] = 0
+		}
+		for s := 0; s < sub; s++ {
+			sy := float64(y) + (float64(s)+0.5)/sub
+			xs = xs[:0]
+			for _, ring := range rings {
+				for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+					a, c := ring[j], ring[i]
+					if (a.Y > sy) != (c.Y > sy) {
+						xs = append(xs, a.X+(sy-a.Y)*(c.X-a.X)/(c.Y-a.Y))
+					}
+				}
+			}
+			sort.Float64s(xs)
+			for k := 0; k+1 < len(xs); k += 2 {
+				addSpan(cov, xs[k]-float64(b.Min.X), xs[k+1]-float64(b.Min.X), 1.0/sub)
+			}
+		}
+		for i, v := range cov {
+			if a := uint8(math.Min(v, 1) * 255); a > mask.AlphaAt(b.Min.X+i, y).A {
+				mask.SetAlpha(b.Min.X+i, y, color.Alpha{A: a})
+			}
+		}
+	}
+}
+
+// pointsRect 环的像素边界
+func pointsRect(rings [][]Point) image.Rectangle {
+	minx, miny, maxx, maxy := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, ring := range rings {
+		for _, p := range ring {
+			minx, miny = math.Min(minx, p.X), math.Min(miny, p.Y)
+			maxx, maxy = math.Max(maxx, p.X), math.Max(maxy, p.Y)
+		}
+	}
+	if minx > maxx {
+		return image.Rectangle{}
+	}
+	return image.Rect(int(math.Floor(minx)), int(math.Floor(miny)), int(math.Ceil(maxx))+1, int(math.Ceil(maxy))+1)
+}
+
+// addSpan 累加 [x0, x1) 的覆盖率, 两端按比例
+func addSpan(cov []float64, x0, x1, w float64) {
+	x0, x1 = math.Max(x0, 0), math.Min(x1, float64(len(cov)))
+	if x0 >= x1 {
+		return
+	}
+	i0, i1 := int(x0), int(x1)
+	if i0 == i1 {
+		cov[i0] += (x1 - x0) * w
+		return
+	}
+	cov[i0] += (float64(i0+1) - x0) * w
+	for i := i0 + 1; i < i1; i++ {
+		cov[i] += w
+	}
+	if i1 < len(cov) {
+		cov[i1] += (x1 - float64(i1)) * w
+	}
+}
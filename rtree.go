@@ -0,0 +1,480 @@
+package xutil
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+	"sync"
+)
+
+/***
+R-tree 空间索引, 以 Geo.Box() 为键
+https://github.com/mourner/rbush                   插入/分裂算法
+https://ieeexplore.ieee.org/document/582015        STR批量装载 (Sort-Tile-Recursive)
+	读写加锁, 可在 ants 协程池中共享查询
+***/
+
+// RTreeItem 索引项
+type RTreeItem struct {
+	ID  interface{}
+	Geo Geo
+	box [4]float64
+}
+
+type rtreeNode struct {
+	box      [4]float64
+	leaf     bool
+	height   int
+	children []*rtreeNode
+	items    []*RTreeItem
+}
+
+// RTree R-tree 空间索引
+type RTree struct {
+	mu         sync.RWMutex
+	root       *rtreeNode
+	size       int
+	maxEntries int
+	minEntries int
+}
+
+// NewRTree 创建R-tree, maxEntries 为节点最大子项数(默认9)
+func NewRTree(maxEntries int) *RTree {
+	if maxEntries < 4 {
+		maxEntries = 9
+	}
+	t := &RTree{maxEntries: maxEntries, minEntries: int(math.Max(2, math.Ceil(float64(maxEntries)*0.4)))}
+	t.root = newRtreeLeaf()
+	return t
+}
+
+func newRtreeLeaf() *rtreeNode {
+	return &rtreeNode{box: emptyBox(), leaf: true, height: 1}
+}
+
+func emptyBox() [4]float64 {
+	return [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+}
+
+func extendBox(a *[4]float64, b [4]float64) {
+	a[0], a[1] = math.Min(a[0], b[0]), math.Min(a[1], b[1])
+	a[2], a[3] = math.Max(a[2], b[2]), math.Max(a[3], b[3])
+}
+
+func boxArea(b [4]float64) float64 {
+	return (b[2] - b[0]) * (b[3] - b[1])
+}
+
+func boxMargin(b [4]float64) float64 {
+	return (b[2] - b[0]) + (b[3] - b[1])
+}
+
+func boxContains(a, b [4]float64) bool {
+	return a[0] <= b[0] && a[1] <= b[1] && b[2] <= a[2] && b[3] <= a[3]
+}
+
+func boxOverlap(a, b [4]float64) bool {
+	return a[0] <= b[2] && b[0] <= a[2] && a[1] <= b[3] && b[1] <= a[3]
+}
+
+func (n *rtreeNode) calcBox() {
+	n.box = emptyBox()
+	for _, c := range n.children {
+		extendBox(&n.box, c.box)
+	}
+	for _, it := range n.items {
+		extendBox(&n.box, it.box)
+	}
+}
+
+func (n *rtreeNode) count() int {
+	if n.leaf {
+		return len(n.items)
+	}
+	return len(n.children)
+}
+
+//===============================================================================
+
+// Len 索引项数量
+func (t *RTree) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.size
+}
+
+// Insert 插入, 空几何对象不入索引
+func (t *RTree) Insert(id interface{}, g Geo) {
+	b := g.Box()
+	if b == nil {
+		return
+	}
+	it := &RTreeItem{ID: id, Geo: g, box: [4]float64{b[0], b[1], b[2], b[3]}}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.insert(it)
+}
+
+func (t *RTree) insert(it *RTreeItem) {
+	// 选择面积增量最小的子树
+	path := []*rtreeNode{}
+	node := t.root
+	for {
+		path = append(path, node)
+		if node.leaf {
+			break
+		}
+		var best *rtreeNode
+		minEnl, minArea := math.Inf(1), math.Inf(1)
+		for _, c := range node.children {
+			area := boxArea(c.box)
+			nb := c.box
+			extendBox(&nb, it.box)
+			enl := boxArea(nb) - area
+			if enl < minEnl || (enl == minEnl && area < minArea) {
+				minEnl, minArea, best = enl, area, c
+			}
+		}
+		node = best
+	}
+	node.items = append(node.items, it)
+	t.size++
+	for _, n := range path {
+		extendBox(&n.box, it.box)
+	}
+
+	// 自底向上分裂溢出节点
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i].count() <= t.maxEntries {
+			break
+		}
+		sibling := t.split(path[i])
+		if i == 0 {
+			t.root = &rtreeNode{height: path[0].height + 1, children: []*rtreeNode{path[0], sibling}}
+			t.root.calcBox()
+		} else {
+			path[i-1].children = append(path[i-1].children, sibling)
+		}
+	}
+}
+
+// split 按周长最小选择分裂轴, 按重叠面积最小选择分裂位置
+func (t *RTree) split(n *rtreeNode) *rtreeNode {
+	m, M := t.minEntries, n.count()
+	boxes := func() []*[4]float64 {
+		bs := make([]*[4]float64, M)
+		for i := 0; i < M; i++ {
+			if n.leaf {
+				bs[i] = &n.items[i].box
+			} else {
+				bs[i] = &n.children[i].box
+			}
+		}
+		return bs
+	}
+	sortBy := func(axis int) {
+		less := func(a, b [4]float64) bool { return a[axis] < b[axis] }
+		if n.leaf {
+			sort.Slice(n.items, func(i, j int) bool { return less(n.items[i].box, n.items[j].box) })
+		} else {
+			sort.Slice(n.children, func(i, j int) bool { return less(n.children[i].box, n.children[j].box) })
+		}
+	}
+	distMargin := func() (margin float64) {
+		bs := boxes()
+		left, right := emptyBox(), emptyBox()
+		for i := 0; i < m; i++ {
+			extendBox(&left, *bs[i])
+			extendBox(&right, *bs[M-1-i])
+		}
+		margin = boxMargin(left) + boxMargin(right)
+		for i := m; i < M-m; i++ {
+			extendBox(&left, *bs[i])
+			margin += boxMargin(left)
+		}
+		for i := M - m - 1; i >= m; i-- {
+			extendBox(&right, *bs[i])
+			margin += boxMargin(right)
+		}
+		return
+	}
+	sortBy(0)
+	xMargin := distMargin()
+	sortBy(1)
+	if xMargin < distMargin() {
+		sortBy(0)
+	}
+
+	bs := boxes()
+	index, minOverlap, minArea := M-m, math.Inf(1), math.Inf(1)
+	for i := m; i <= M-m; i++ {
+		b1, b2 := emptyBox(), emptyBox()
+		for j := 0; j < i; j++ {
+			extendBox(&b1, *bs[j])
+		}
+		for j := i; j < M; j++ {
+			extendBox(&b2, *bs[j])
+		}
+		overlap := 0.0
+		if boxOverlap(b1, b2) {
+			overlap = boxArea([4]float64{math.Max(b1[0], b2[0]), math.Max(b1[1], b2[1]), math.Min(b1[2], b2[2]), math.Min(b1[3], b2[3])})
+		}
+		area := boxArea(b1) + boxArea(b2)
+		if overlap < minOverlap || (overlap == minOverlap && area < minArea) {
+			index, minOverlap, minArea = i, overlap, area
+		}
+	}
+
+	sibling := &rtreeNode{leaf: n.leaf, height: n.height}
+	if n.leaf {
+		sibling.items = append(sibling.items, n.items[index:]...)
+		n.items = n.items[:index:index]
+	} else {
+		sibling.children = append(sibling.children, n.children[index:]...)
+		n.children = n.children[:index:index]
+	}
+	n.calcBox()
+	sibling.calcBox()
+	return sibling
+}
+
+// Load 批量装载(STR), 与已有索引项合并后重建
+func (t *RTree) Load(items []RTreeItem) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	all := t.all(t.root, nil)
+	for _, it := range items {
+		b := it.Geo.Box()
+		if b == nil {
+			continue
+		}
+		all = append(all, &RTreeItem{ID: it.ID, Geo: it.Geo, box: [4]float64{b[0], b[1], b[2], b[3]}})
+	}
+	t.size = len(all)
+	if len(all) <= t.maxEntries {
+		t.root = newRtreeLeaf()
+		t.root.items = all
+		t.root.calcBox()
+		return
+	}
+
+	var nodes []*rtreeNode
+	strTile(len(all), t.maxEntries, func(lo, hi, axis int) {
+		s := all[lo:hi]
+		sort.Slice(s, func(i, j int) bool { return s[i].box[axis]+s[i].box[axis+2] < s[j].box[axis]+s[j].box[axis+2] })
+	}, func(lo, hi int) {
+		n := newRtreeLeaf()
+		n.items = append(n.items, all[lo:hi]...)
+		n.calcBox()
+		nodes = append(nodes, n)
+	})
+	for len(nodes) > 1 {
+		children := nodes
+		nodes = nil
+		strTile(len(children), t.maxEntries, func(lo, hi, axis int) {
+			s := children[lo:hi]
+			sort.Slice(s, func(i, j int) bool { return s[i].box[axis]+s[i].box[axis+2] < s[j].box[axis]+s[j].box[axis+2] })
+		}, func(lo, hi int) {
+			n := &rtreeNode{height: children[lo].height + 1}
+			n.children = append(n.children, children[lo:hi]...)
+			n.calcBox()
+			nodes = append(nodes, n)
+		})
+	}
+	t.root = nodes[0]
+}
+
+// strTile STR分块: 整体按x排序后切片, 片内按y排序, 每M个打包为一个节点
+func strTile(n, M int, sortRange func(lo, hi, axis int), pack func(lo, hi int)) {
+	sortRange(0, n, 0)
+	pages := int(math.Ceil(float64(n) / float64(M)))
+	sliceSize := int(math.Ceil(math.Sqrt(float64(pages)))) * M
+	for i := 0; i < n; i += sliceSize {
+		hi := i + sliceSize
+		if hi > n {
+			hi = n
+		}
+		sortRange(i, hi, 1)
+		for j := i; j < hi; j += M {
+			end := j + M
+			if end > hi {
+				end = hi
+			}
+			pack(j, end)
+		}
+	}
+}
+
+func (t *RTree) all(n *rtreeNode, items []*RTreeItem) []*RTreeItem {
+	items = append(items, n.items...)
+	for _, c := range n.children {
+		items = t.all(c, items)
+	}
+	return items
+}
+
+// Delete 按ID删除(ID须可比较), 返回是否删除
+func (t *RTree) Delete(id interface{}, g Geo) bool {
+	b := g.Box()
+	if b == nil {
+		return false
+	}
+	box := [4]float64{b[0], b[1], b[2], b[3]}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.remove(t.root, id, box) {
+		return false
+	}
+	t.size--
+	for !t.root.leaf && len(t.root.children) == 1 {
+		t.root = t.root.children[0]
+	}
+	if !t.root.leaf && len(t.root.children) == 0 {
+		t.root = newRtreeLeaf()
+	}
+	return true
+}
+
+// remove 递归删除, 删除后去掉空节点并重算边界
+func (t *RTree) remove(n *rtreeNode, id interface{}, box [4]float64) bool {
+	if !boxContains(n.box, box) {
+		return false
+	}
+	if n.leaf {
+		for i, it := range n.items {
+			if it.ID == id && it.box == box {
+				n.items = append(n.items[:i], n.items[i+1:]...)
+				n.calcBox()
+				return true
+			}
+		}
+		return false
+	}
+	for i, c := range n.children {
+		if t.remove(c, id, box) {
+			if c.count() == 0 {
+				n.children = append(n.children[:i], n.children[i+1:]...)
+			}
+			n.calcBox()
+			return true
+		}
+	}
+	return false
+}
+
+//===============================================================================
+
+// All 所有索引项
+func (t *RTree) All() []RTreeItem {
+	return t.Search([]float64{math.Inf(-1), math.Inf(-1), math.Inf(1), math.Inf(1)})
+}
+
+// Search 查询与边界框 minx, miny, maxx, maxy 相交的索引项
+func (t *RTree) Search(box []float64) (items []RTreeItem) {
+	if len(box) < 4 {
+		return nil
+	}
+	b := [4]float64{box[0], box[1], box[2], box[3]}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	t.search(t.root, b, func(it *RTreeItem) {
+		items = append(items, *it)
+	})
+	return items
+}
+
+func (t *RTree) search(n *rtreeNode, b [4]float64, f func(it *RTreeItem)) {
+	if !boxOverlap(n.box, b) {
+		return
+	}
+	for _, it := range n.items {
+		if boxOverlap(it.box, b) {
+			f(it)
+		}
+	}
+	for _, c := range n.children {
+		t.search(c, b, f)
+	}
+}
+
+// Containing 查询包含点(含边界)的索引项, 用于点落面
+func (t *RTree) Containing(p Point) (items []RTreeItem) {
+	b := [4]float64{p.X, p.Y, p.X, p.Y}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	t.search(t.root, b, func(it *RTreeItem) {
+		if it.Geo.Covers(p) {
+			items = append(items, *it)
+		}
+	})
+	return items
+}
+
+// boxDistance 点到边界框(经纬度范围)的最短球面距离(米), 是框内几何对象距离的下界
+// 点的经度在框内时最近点在同一经线上; 否则在较近的经线边上, 取点到该经线大圆的垂足, 垂足超出纬度范围时取角点
+func boxDistance(p Point, b [4]float64) float64 {
+	if p.X >= b[0] && p.X <= b[2] {
+		return PointDistHaversine(p.X, p.Y, p.X, math.Max(b[1], math.Min(p.Y, b[3])))
+	}
+	x := b[0]
+	if math.Abs(normLon(p.X-b[2])) < math.Abs(normLon(p.X-b[0])) {
+		x = b[2]
+	}
+	if c := math.Cos(Radians(p.X - x)); c > 0 {
+		if y := Degrees(math.Atan(math.Tan(Radians(p.Y)) / c)); y > b[1] && y < b[3] {
+			return PointDistHaversine(p.X, p.Y, x, y)
+		}
+	}
+	return math.Min(PointDistHaversine(p.X, p.Y, x, b[1]), PointDistHaversine(p.X, p.Y, x, b[3]))
+}
+
+type rtreeQueueItem struct {
+	dist  float64
+	node  *rtreeNode
+	item  *RTreeItem
+	exact bool // item 的 dist 为到几何对象的距离, 否则为到边界框的距离
+}
+
+type rtreeQueue []rtreeQueueItem
+
+func (q rtreeQueue) Len() int            { return len(q) }
+func (q rtreeQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q rtreeQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *rtreeQueue) Push(x interface{}) { *q = append(*q, x.(rtreeQueueItem)) }
+func (q *rtreeQueue) Pop() interface{} {
+	old := *q
+	x := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return x
+}
+
+// Nearest 距离点最近的k个索引项(按到几何对象的球面距离, 米, 见 Geo.DistanceTo), 返回距离
+func (t *RTree) Nearest(p Point, k int) (items []RTreeItem, dists []float64) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	q := &rtreeQueue{{dist: boxDistance(p, t.root.box), node: t.root}}
+	for q.Len() > 0 && len(items) < k {
+		e := heap.Pop(q).(rtreeQueueItem)
+		switch {
+		case e.exact:
+			items = append(items, *e.item)
+			dists = append(dists, e.dist)
+		case e.item != nil:
+			// 边界框距离是下界, 出队时再计算到几何对象的距离
+			heap.Push(q, rtreeQueueItem{dist: e.item.Geo.DistanceTo(p), item: e.item, exact: true})
+		default:
+			for _, it := range e.node.items {
+				heap.Push(q, rtreeQueueItem{dist: boxDistance(p, it.box), item: it})
+			}
+			for _, c := range e.node.children {
+				heap.Push(q, rtreeQueueItem{dist: boxDistance(p, c.box), node: c})
+			}
+		}
+	}
+	return
+}
+
+// Box 索引项边界框 minx, miny, maxx, maxy
+func (it RTreeItem) Box() []float64 {
+	return []float64{it.box[0], it.box[1], it.box[2], it.box[3]}
+}
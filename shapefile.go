@@ -0,0 +1,952 @@
+package xutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/axgle/mahonia"
+)
+
+/***
+ESRI Shapefile 读写
+https://www.esri.com/content/dam/esrisites/sitecore-archive/Files/Pdfs/library/whitepapers/pdfs/shapefile.pdf
+https://www.clicketyclick.dk/databases/xbase/format/dbf.html  DBF
+	支持 Point PolyLine Polygon MultiPoint 及其 Z/M 类型, 不支持 MultiPatch
+	Z类型为 XYZ, M值全部为"无数据"(小于-1e38)时不保留M, 否则为 XYZM/XYM, 无数据的M为NaN
+	面: 顺时针环为外环, 逆时针环归入包含它的最小外环, 读取后外环逆时针、内环顺时针; 写出时外环顺时针、内环逆时针
+	DBF编码: 指定 encoding > .cpg > 语言驱动(0x4D/0x7A 为GBK), 都没有时合法UTF-8按UTF-8, 否则按GBK
+	.prj 为坐标系WKT, SRID() 识别常用的EPSG编码, 识别成功时设置几何对象的 CRS
+***/
+
+const _shpNoData = -1e39
+
+var _shpTypes = map[int]string{1: "Point", 3: "PolyLine", 5: "Polygon", 8: "MultiPoint"}
+
+// Shapefile Shapefile图层
+type Shapefile struct {
+	Name      string     // 不带扩展名的文件名
+	ShapeType int        // 1点 3线 5面 8多点, Z类型加10, M类型加20; 写出时为0则按几何对象确定
+	Fields    []DBFField // 属性字段, 写出时为空则按属性推断
+	Features  []Feature
+	Prj       string // 坐标系WKT(.prj)
+	Encoding  string // DBF编码, 写出时为空按UTF-8
+}
+
+// DBFField DBF字段
+type DBFField struct {
+	Name     string
+	Type     byte // C字符 N/F数值 L逻辑 D日期
+	Length   int
+	Decimals int
+}
+
+// ReadShapefile 读取 .shp 及同名的 .dbf .prj .cpg, encoding 为空时自动识别
+func ReadShapefile(filename, encoding string) (*Shapefile, error) {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	files := make(map[string][]byte)
+	for _, ext := range []string{".shp", ".dbf", ".prj", ".cpg"} {
+		for _, name := range []string{base + ext, base + strings.ToUpper(ext)} {
+			if b, err := os.ReadFile(name); err == nil {
+				files[name] = b
+				break
+			}
+		}
+	}
+	layers, err := ParseShapefile(files, encoding)
+	if err != nil {
+		return nil, err
+	}
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("shp文件不存在: %s", filename)
+	}
+	return layers[0], nil
+}
+
+// ParseShapefile 解析文件集合(如 UncompressZip 的结果)中的所有图层, 按文件名排序
+func ParseShapefile(files map[string][]byte, encoding string) ([]*Shapefile, error) {
+	groups := make(map[string]map[string][]byte)
+	for name, b := range files {
+		ext := strings.ToLower(filepath.Ext(name))
+		base := strings.TrimSuffix(name, filepath.Ext(name))
+		if groups[base] == nil {
+			groups[base] = make(map[string][]byte)
+		}
+		groups[base][ext] = b
+	}
+	var bases []string
+	for base, g := range groups {
+		if _, ok := g[".shp"]; ok {
+			bases = append(bases, base)
+		}
+	}
+	sort.Strings(bases)
+
+	var layers []*Shapefile
+	for _, base := range bases {
+		g := groups[base]
+		s := &Shapefile{Name: filepath.Base(base), Prj: strings.TrimSpace(string(g[".prj"])), Encoding: encoding}
+		if s.Encoding == "" {
+			s.Encoding = strings.TrimSpace(string(g[".cpg"]))
+		}
+		geos, err := s.readShp(g[".shp"])
+		if err != nil {
+			return nil, fmt.Errorf("%s.shp: %v", s.Name, err)
+		}
+		if dbf, ok := g[".dbf"]; ok {
+			if err = s.readDBF(dbf, geos); err != nil {
+				return nil, fmt.Errorf("%s.dbf: %v", s.Name, err)
+			}
+		} else {
+			for _, geo := range geos {
+				s.Features = append(s.Features, NewFeature(geo))
+			}
+		}
+		if srid := s.SRID(); srid != 0 {
+			for i := range s.Features {
+				s.Features[i].Geometry.CRS = "EPSG:" + strconv.Itoa(srid)
+			}
+		}
+		layers = append(layers, s)
+	}
+	return layers, nil
+}
+
+// NewShapefile 要素集合转Shapefile图层, 类型与字段在写出时确定
+func NewShapefile(fc FeatureCollection) *Shapefile {
+	return &Shapefile{Features: fc.Features}
+}
+
+// FeatureCollection 图层的要素集合
+func (s *Shapefile) FeatureCollection() FeatureCollection {
+	return FeatureCollection{Features: s.Features}
+}
+
+//===============================================================================
+
+// readShp 解析 .shp 的所有记录
+func (s *Shapefile) readShp(b []byte) ([]Geo, error) {
+	if len(b) < 100 || binary.BigEndian.Uint32(b) != 9994 {
+		return nil, errors.New("不是shp文件")
+	}
+	s.ShapeType = int(binary.LittleEndian.Uint32(b[32:]))
+	var geos []Geo
+	for off := 100; off+8 <= len(b); {
+		n := int(binary.BigEndian.Uint32(b[off+4:])) * 2
+		if off+8+n > len(b) {
+			return nil, fmt.Errorf("第%d条记录长度错误", len(geos)+1)
+		}
+		g, err := shpGeo(b[off+8 : off+8+n])
+		if err != nil {
+			return nil, fmt.Errorf("第%d条记录: %v", len(geos)+1, err)
+		}
+		geos = append(geos, g)
+		off += 8 + n
+	}
+	return geos, nil
+}
+
+// shpGeo 解析记录内容, 空形状返回 Type 为空的Geo
+func shpGeo(b []byte) (g Geo, err error) {
+	if len(b) < 4 {
+		return g, errors.New("记录过短")
+	}
+	t := int(binary.LittleEndian.Uint32(b))
+	if t == 0 {
+		return g, nil
+	}
+	base, hasZ, hasM := t%10, t/10 == 1, t/10 == 2
+	if _shpTypes[base] == "" || t > 28 {
+		return g, fmt.Errorf("不支持的形状类型: %d", t)
+	}
+	f64 := func(off int) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b[off:])) }
+
+	// 点数、各部分起点及坐标起始位置
+	n, parts, off := 1, []int{0}, 4
+	switch base {
+	case 8:
+		if len(b) < 40 {
+			return g, errors.New("记录过短")
+		}
+		n, off = int(binary.LittleEndian.Uint32(b[36:])), 40
+	case 3, 5:
+		if len(b) < 44 {
+			return g, errors.New("记录过短")
+		}
+		np := int(binary.LittleEndian.Uint32(b[36:]))
+		n = int(binary.LittleEndian.Uint32(b[40:]))
+		if np < 0 || np > (len(b)-44)/4 {
+			return g, errors.New("部分数量错误")
+		}
+		parts = make([]int, np)
+		for i := range parts {
+			parts[i] = int(binary.LittleEndian.Uint32(b[44+4*i:]))
+			if parts[i] < 0 || parts[i] > n || (i > 0 && parts[i] < parts[i-1]) {
+				return g, errors.New("部分起点错误")
+			}
+		}
+		off = 44 + 4*np
+	}
+	if n < 0 || n > (len(b)-off)/16 {
+		return g, errors.New("点数量错误")
+	}
+
+	stride := 2
+	zOff, mOff := -1, -1
+	if base == 1 {
+		if hasZ {
+			zOff = off + 16
+		}
+		if hasZ && len(b) >= off+32 || hasM && len(b) >= off+24 {
+			mOff = off + 16
+			if hasZ {
+				mOff += 8
+			}
+		}
+	} else {
+		next := off + 16*n
+		if hasZ {
+			zOff, next = next+16, next+16+8*n
+		}
+		if (hasZ || hasM) && len(b) >= next+16+8*n {
+			mOff = next + 16
+		}
+	}
+	if hasM && mOff < 0 || zOff >= 0 && len(b) < zOff+8*n {
+		return g, errors.New("记录过短")
+	}
+	if zOff >= 0 {
+		stride++
+	}
+	// M值全部为无数据时不保留
+	keepM := false
+	for i := 0; mOff >= 0 && i < n; i++ {
+		keepM = keepM || f64(mOff+8*i) >= -1e38
+	}
+	if keepM {
+		stride++
+	}
+	g.Dim = [...]string{"", "", "XYZ", "XYZM"}[stride-1]
+	if zOff < 0 && keepM {
+		g.Dim = "XYM"
+	}
+
+	cs := make([][]float64, n)
+	for i := range cs {
+		c := make([]float64, 2, stride)
+		c[0], c[1] = f64(off+16*i), f64(off+16*i+8)
+		if zOff >= 0 {
+			c = append(c, f64(zOff+8*i))
+		}
+		if keepM {
+			m := f64(mOff + 8*i)
+			if m < -1e38 {
+				m = math.NaN()
+			}
+			c = append(c, m)
+		}
+		cs[i] = c
+	}
+
+	switch base {
+	case 1:
+		g.Type, g.Coords = "Point", [][][][]float64{{cs}}
+	case 8:
+		g.Type, g.Coords = "MultiPoint", [][][][]float64{{cs}}
+	default:
+		var lines [][][]float64
+		for i, p := range parts {
+			end := n
+			if i+1 < len(parts) {
+				end = parts[i+1]
+			}
+			if end > p {
+				lines = append(lines, cs[p:end])
+			}
+		}
+		dim := g.Dim
+		switch {
+		case base == 5:
+			g = shpPolygon(lines)
+		case len(lines) == 1:
+			g = Geo{Type: "LineString", Coords: [][][][]float64{lines}}
+		default:
+			g = Geo{Type: "MultiLineString", Coords: [][][][]float64{lines}}
+		}
+		g.Dim = dim
+	}
+	return g, nil
+}
+
+// shpPolygon 顺时针环为外环, 其余环归入包含它的最小外环
+func shpPolygon(rings [][][]float64) Geo {
+	var shells [][][][]float64
+	var holes [][][]float64
+	for _, ring := range rings {
+		if ringSignedArea(ring) < 0 {
+			shells = append(shells, [][][]float64{ring})
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+	if len(shells) == 0 {
+		return ringsGeo(rings)
+	}
+	for _, hole := range holes {
+		owner, area := -1, math.Inf(1)
+		for i, shell := range shells {
+			a := -ringSignedArea(shell[0])
+			if a < area && locateRings(shell[:1], coordPoint(hole[0])) != locExterior {
+				owner, area = i, a
+			}
+		}
+		if owner >= 0 {
+			shells[owner] = append(shells[owner], hole)
+		} else {
+			shells = append(shells, [][][]float64{hole})
+		}
+	}
+	// 转为外环逆时针、内环顺时针
+	for _, poly := range shells {
+		for i, ring := range poly {
+			if (ringSignedArea(ring) < 0) == (i == 0) {
+				poly[i] = reverseCoords(ring)
+			}
+		}
+	}
+	return polysGeo(shells)
+}
+
+//===============================================================================
+
+// dbfCharset 编码名称规范化, .cpg 中常见代码页编号
+func dbfCharset(encoding string) string {
+	switch strings.ToUpper(strings.TrimSpace(encoding)) {
+	case "936", "CP936", "GB2312", "GBK":
+		return "GBK"
+	case "65001", "UTF-8", "UTF8":
+		return "UTF-8"
+	}
+	return strings.TrimSpace(encoding)
+}
+
+// dbfDecoder 字符串解码, 未知编码时按UTF-8合法性在UTF-8与GBK间选择
+func dbfDecoder(encoding string, ldid byte) (func(b []byte) string, error) {
+	encoding = dbfCharset(encoding)
+	if encoding == "" && (ldid == 0x4D || ldid == 0x7A) {
+		encoding = "GBK"
+	}
+	switch encoding {
+	case "":
+		gbk := mahonia.NewDecoder("GBK")
+		return func(b []byte) string {
+			if utf8.Valid(b) {
+				return string(b)
+			}
+			return gbk.ConvertString(string(b))
+		}, nil
+	case "UTF-8":
+		return func(b []byte) string { return string(b) }, nil
+	}
+	d := mahonia.NewDecoder(encoding)
+	if d == nil {
+		return nil, fmt.Errorf("不支持的编码: %s", encoding)
+	}
+	return func(b []byte) string { return d.ConvertString(string(b)) }, nil
+}
+
+// readDBF 解析 .dbf, 与几何对象按顺序对应, 跳过已删除的记录
+func (s *Shapefile) readDBF(b []byte, geos []Geo) error {
+	if len(b) < 32 {
+		return errors.New("不是dbf文件")
+	}
+	n := int(binary.LittleEndian.Uint32(b[4:]))
+	headLen, recLen := int(binary.LittleEndian.Uint16(b[8:])), int(binary.LittleEndian.Uint16(b[10:]))
+	if headLen > len(b) || recLen < 1 {
+		return errors.New("dbf文件头错误")
+	}
+	decode, err := dbfDecoder(s.Encoding, b[29])
+	if err != nil {
+		return err
+	}
+	for off := 32; off+32 <= headLen && b[off] != 0x0D; off += 32 {
+		fd := b[off : off+32]
+		name := fd[:11]
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+		s.Fields = append(s.Fields, DBFField{Name: strings.TrimSpace(decode(name)), Type: fd[11], Length: int(fd[16]), Decimals: int(fd[17])})
+	}
+
+	for i := 0; i < n; i++ {
+		off := headLen + i*recLen
+		if off+recLen > len(b) {
+			return fmt.Errorf("第%d条记录不完整", i+1)
+		}
+		rec := b[off : off+recLen]
+		if rec[0] == '*' {
+			continue
+		}
+		var g Geo
+		if i < len(geos) {
+			g = geos[i]
+		}
+		f := NewFeature(g)
+		pos := 1
+		for _, fd := range s.Fields {
+			if pos+fd.Length > len(rec) {
+				return fmt.Errorf("第%d条记录字段长度错误", i+1)
+			}
+			f.Properties[fd.Name] = dbfValue(fd, rec[pos:pos+fd.Length], decode)
+			pos += fd.Length
+		}
+		s.Features = append(s.Features, f)
+	}
+	return nil
+}
+
+// dbfValue 字段值: 数值为float64, 逻辑为bool, 空值为nil, 其余为字符串
+func dbfValue(fd DBFField, b []byte, decode func([]byte) string) interface{} {
+	switch fd.Type {
+	case 'N', 'F':
+		v, err := strconv.ParseFloat(strings.TrimSpace(string(b)), 64)
+		if err != nil {
+			return nil
+		}
+		return v
+	case 'L':
+		switch strings.ToUpper(strings.TrimSpace(string(b))) {
+		case "T", "Y":
+			return true
+		case "F", "N":
+			return false
+		}
+		return nil
+	case 'C':
+		return strings.TrimRight(decode(bytes.TrimRight(b, "\x00")), " ")
+	}
+	return strings.TrimSpace(decode(b))
+}
+
+//===============================================================================
+
+// Save 写出 .shp .shx .dbf .cpg 及 .prj(Prj 不为空时), filename 的扩展名会被忽略
+func (s *Shapefile) Save(filename string) error {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	files, err := s.Files(filepath.Base(base))
+	if err != nil {
+		return err
+	}
+	for name, b := range files {
+		if err = os.WriteFile(filepath.Join(filepath.Dir(base), name), b, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Files 生成各文件内容, 键为 name 加扩展名
+func (s *Shapefile) Files(name string) (map[string][]byte, error) {
+	shp, shx, err := s.shpBytes()
+	if err != nil {
+		return nil, err
+	}
+	dbf, err := s.dbfBytes()
+	if err != nil {
+		return nil, err
+	}
+	encoding := s.Encoding
+	if encoding == "" {
+		encoding = "UTF-8"
+	}
+	files := map[string][]byte{name + ".shp": shp, name + ".shx": shx, name + ".dbf": dbf, name + ".cpg": []byte(encoding)}
+	if s.Prj != "" {
+		files[name+".prj"] = []byte(s.Prj)
+	}
+	return files, nil
+}
+
+// shpBase 几何对象对应的基本形状类型, 空对象为0
+func shpBase(g Geo) (int, error) {
+	if g.Type == "" || g.IsEmpty() {
+		return 0, nil
+	}
+	switch g.Type {
+	case "Point":
+		return 1, nil
+	case "MultiPoint":
+		return 8, nil
+	case "LineString", "MultiLineString":
+		return 3, nil
+	case "Polygon", "MultiPolygon":
+		return 5, nil
+	}
+	return 0, fmt.Errorf("shp不支持的几何类型: %s", g.Type)
+}
+
+// shapeType 确定图层的形状类型
+func (s *Shapefile) shapeType() (int, error) {
+	if s.ShapeType != 0 {
+		return s.ShapeType, nil
+	}
+	for _, f := range s.Features {
+		base, err := shpBase(f.Geometry)
+		if err != nil || base == 0 {
+			continue
+		}
+		switch {
+		case f.Geometry.HasZ():
+			return base + 10, nil
+		case f.Geometry.HasM():
+			return base + 20, nil
+		}
+		return base, nil
+	}
+	return 0, nil
+}
+
+// shpBytes 生成 .shp 与 .shx
+func (s *Shapefile) shpBytes() (shp, shx []byte, err error) {
+	t, err := s.shapeType()
+	if err != nil {
+		return nil, nil, err
+	}
+	hasZ, hasM := t/10 == 1, t/10 != 0
+	box := []float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1), math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)}
+	shp, shx = make([]byte, 100), make([]byte, 100)
+	for i, f := range s.Features {
+		base, err := shpBase(f.Geometry)
+		if err != nil {
+			return nil, nil, fmt.Errorf("第%d个要素: %v", i+1, err)
+		}
+		if base != 0 && base != t%10 {
+			return nil, nil, fmt.Errorf("第%d个要素: %s 与图层类型 %s 不一致", i+1, f.Geometry.Type, _shpTypes[t%10])
+		}
+		rec := []byte{0, 0, 0, 0}
+		if base != 0 {
+			rec = shpRecord(f.Geometry, t, box)
+		}
+		shx = binary.BigEndian.AppendUint32(shx, uint32(len(shp)/2))
+		shx = binary.BigEndian.AppendUint32(shx, uint32(len(rec)/2))
+		shp = binary.BigEndian.AppendUint32(shp, uint32(i+1))
+		shp = binary.BigEndian.AppendUint32(shp, uint32(len(rec)/2))
+		shp = append(shp, rec...)
+	}
+	if box[0] > box[2] {
+		box = make([]float64, 8)
+	}
+	if !hasZ {
+		box[4], box[5] = 0, 0
+	}
+	if !hasM || box[6] > box[7] {
+		box[6], box[7] = 0, 0
+	}
+	for _, h := range [][]byte{shp, shx} {
+		binary.BigEndian.PutUint32(h, 9994)
+		binary.BigEndian.PutUint32(h[24:], uint32(len(h)/2))
+		binary.LittleEndian.PutUint32(h[28:], 1000)
+		binary.LittleEndian.PutUint32(h[32:], uint32(t))
+		for i, v := range box {
+			binary.LittleEndian.PutUint64(h[36+8*i:], math.Float64bits(v))
+		}
+	}
+	return shp, shx, nil
+}
+
+// shpRecord 生成记录内容, 并扩展图层的 x y z m 范围
+func shpRecord(g Geo, t int, box []float64) []byte {
+	hasZ, hasM := t/10 == 1, t/10 != 0
+	zi, mi := -1, -1
+	if g.HasZ() {
+		zi = 2
+	}
+	if g.HasM() {
+		mi = g.Stride() - 1
+	}
+	// 各部分坐标, 面的外环顺时针、内环逆时针
+	var parts [][][]float64
+	g.eachPart(func(c []float64) {
+		parts = append(parts, [][]float64{c})
+	}, func(b [][]float64) {
+		parts = append(parts, b)
+	}, func(a [][][]float64) {
+		for i, ring := range a {
+			if len(ring) > 0 && !pointEqual(coordPoint(ring[0]), coordPoint(ring[len(ring)-1])) {
+				ring = append(ring[:len(ring):len(ring)], ring[0])
+			}
+			if (ringSignedArea(ring) > 0) == (i == 0) {
+				ring = reverseCoords(ring)
+			}
+			parts = append(parts, ring)
+		}
+	})
+	// 记录的范围 minx, miny, maxx, maxy, minz, maxz, minm, maxm
+	r := []float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1), math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)}
+	var xys, zs, ms []float64
+	var starts []int
+	for _, p := range parts {
+		starts = append(starts, len(zs))
+		for _, c := range p {
+			z, m := 0.0, _shpNoData
+			if zi >= 0 && len(c) > zi {
+				z = c[zi]
+			}
+			if mi >= 0 && len(c) > mi && !math.IsNaN(c[mi]) {
+				m = c[mi]
+				r[6], r[7] = math.Min(r[6], m), math.Max(r[7], m)
+			}
+			r[0], r[1], r[2], r[3] = math.Min(r[0], c[0]), math.Min(r[1], c[1]), math.Max(r[2], c[0]), math.Max(r[3], c[1])
+			r[4], r[5] = math.Min(r[4], z), math.Max(r[5], z)
+			xys, zs, ms = append(xys, c[0], c[1]), append(zs, z), append(ms, m)
+		}
+	}
+	box[0], box[1], box[2], box[3] = math.Min(box[0], r[0]), math.Min(box[1], r[1]), math.Max(box[2], r[2]), math.Max(box[3], r[3])
+	box[4], box[5], box[6], box[7] = math.Min(box[4], r[4]), math.Max(box[5], r[5]), math.Min(box[6], r[6]), math.Max(box[7], r[7])
+	if r[6] > r[7] {
+		r[6], r[7] = _shpNoData, _shpNoData
+	}
+
+	le := binary.LittleEndian
+	put := func(b []byte, vs ...float64) []byte {
+		for _, v := range vs {
+			b = le.AppendUint64(b, math.Float64bits(v))
+		}
+		return b
+	}
+	b := le.AppendUint32(nil, uint32(t))
+	if t%10 == 1 {
+		b = put(b, xys...)
+		if hasZ {
+			b = put(b, zs...)
+		}
+		if hasM {
+			b = put(b, ms...)
+		}
+		return b
+	}
+	b = put(b, r[:4]...)
+	if t%10 != 8 {
+		b = le.AppendUint32(b, uint32(len(starts)))
+	}
+	b = le.AppendUint32(b, uint32(len(zs)))
+	if t%10 != 8 {
+		for _, p := range starts {
+			b = le.AppendUint32(b, uint32(p))
+		}
+	}
+	b = put(b, xys...)
+	if hasZ {
+		b = put(put(b, r[4:6]...), zs...)
+	}
+	if hasM {
+		b = put(put(b, r[6:8]...), ms...)
+	}
+	return b
+}
+
+// reverseCoords 反转坐标顺序, 不修改原数组
+func reverseCoords(cs [][]float64) [][]float64 {
+	r := make([][]float64, len(cs))
+	for i, c := range cs {
+		r[len(cs)-1-i] = c
+	}
+	return r
+}
+
+//===============================================================================
+
+// dbfEncoder 字符串编码, 为空时按UTF-8
+func dbfEncoder(encoding string) (func(s string) []byte, error) {
+	switch encoding = dbfCharset(encoding); encoding {
+	case "", "UTF-8":
+		return func(s string) []byte { return []byte(s) }, nil
+	}
+	e := mahonia.NewEncoder(encoding)
+	if e == nil {
+		return nil, fmt.Errorf("不支持的编码: %s", encoding)
+	}
+	return func(s string) []byte { return []byte(e.ConvertString(s)) }, nil
+}
+
+// dbfFloat 数值属性
+func dbfFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// dbfFields 按属性推断字段: 全为数值时为N, 全为bool时为L, 否则为C
+func (s *Shapefile) dbfFields(encode func(string) []byte) []DBFField {
+	kinds := make(map[string]byte)
+	for _, f := range s.Features {
+		for k, v := range f.Properties {
+			if v == nil {
+				if _, ok := kinds[k]; !ok {
+					kinds[k] = 0
+				}
+				continue
+			}
+			kind := byte('C')
+			if _, ok := dbfFloat(v); ok {
+				kind = 'N'
+			} else if _, ok := v.(bool); ok {
+				kind = 'L'
+			}
+			if old, ok := kinds[k]; ok && old != 0 && old != kind {
+				kind = 'C'
+			}
+			kinds[k] = kind
+		}
+	}
+	var fields []DBFField
+	for k, kind := range kinds {
+		fd := DBFField{Name: k, Type: kind, Length: 1}
+		switch kind {
+		case 'L':
+		case 'N':
+			intLen := 1
+			for _, f := range s.Features {
+				if v, ok := dbfFloat(f.Properties[k]); ok {
+					str := strconv.FormatFloat(v, 'f', -1, 64)
+					if i := strings.IndexByte(str, '.'); i >= 0 {
+						if len(str)-i-1 > fd.Decimals {
+							fd.Decimals = len(str) - i - 1
+						}
+						str = str[:i]
+					}
+					if len(str) > intLen {
+						intLen = len(str)
+					}
+				}
+			}
+			if fd.Decimals > 15 {
+				fd.Decimals = 15
+			}
+			fd.Length = intLen
+			if fd.Decimals > 0 {
+				fd.Length += fd.Decimals + 1
+			}
+			if fd.Length > 20 {
+				fd.Length = 20
+			}
+		default:
+			fd.Type = 'C'
+			for _, f := range s.Features {
+				if v := f.Properties[k]; v != nil && len(encode(propString(v))) > fd.Length {
+					fd.Length = len(encode(propString(v)))
+				}
+			}
+			if fd.Length > 254 {
+				fd.Length = 254
+			}
+		}
+		fields = append(fields, fd)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	return fields
+}
+
+// dbfBytes 生成 .dbf
+func (s *Shapefile) dbfBytes() ([]byte, error) {
+	encode, err := dbfEncoder(s.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	fields := s.Fields
+	if len(fields) == 0 {
+		fields = s.dbfFields(encode)
+	}
+	// 没有属性时写出序号字段, 部分软件不能读取无字段的dbf
+	autoID := len(fields) == 0
+	if autoID {
+		fields = []DBFField{{Name: "ID", Type: 'N', Length: 10}}
+	}
+	recLen := 1
+	for _, fd := range fields {
+		recLen += fd.Length
+	}
+	headLen := 32 + 32*len(fields) + 1
+	if headLen > math.MaxUint16 || recLen > math.MaxUint16 {
+		return nil, errors.New("dbf字段过多")
+	}
+
+	now := time.Now()
+	b := make([]byte, 32, headLen+recLen*len(s.Features)+1)
+	b[0], b[1], b[2], b[3] = 0x03, byte(now.Year()-1900), byte(now.Month()), byte(now.Day())
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(s.Features)))
+	binary.LittleEndian.PutUint16(b[8:], uint16(headLen))
+	binary.LittleEndian.PutUint16(b[10:], uint16(recLen))
+	if dbfCharset(s.Encoding) == "GBK" {
+		b[29] = 0x4D
+	}
+	for _, fd := range fields {
+		fb := make([]byte, 32)
+		copy(fb, dbfName(fd.Name, encode))
+		fb[11], fb[16], fb[17] = fd.Type, byte(fd.Length), byte(fd.Decimals)
+		b = append(b, fb...)
+	}
+	b = append(b, 0x0D)
+
+	for i, f := range s.Features {
+		b = append(b, ' ')
+		for _, fd := range fields {
+			v := f.Properties[fd.Name]
+			if autoID {
+				v = i + 1
+			}
+			b = append(b, dbfFormat(fd, v, encode)...)
+		}
+	}
+	return append(b, 0x1A), nil
+}
+
+// dbfName 字段名最长10字节, 按字符截断
+func dbfName(name string, encode func(string) []byte) []byte {
+	rs := []rune(name)
+	for len(encode(string(rs))) > 10 {
+		rs = rs[:len(rs)-1]
+	}
+	return encode(string(rs))
+}
+
+// dbfFormat 字段值按长度格式化, 数值右对齐, 超长的数值填充 *
+func dbfFormat(fd DBFField, v interface{}, encode func(string) []byte) []byte {
+	out := bytes.Repeat([]byte{' '}, fd.Length)
+	if v == nil {
+		return out
+	}
+	switch fd.Type {
+	case 'N', 'F':
+		f, ok := dbfFloat(v)
+		if !ok {
+			if f, ok = dbfFloat(json.Number(strings.TrimSpace(propString(v)))); !ok {
+				return out
+			}
+		}
+		str := strconv.FormatFloat(f, 'f', fd.Decimals, 64)
+		if len(str) > fd.Length {
+			return bytes.Repeat([]byte{'*'}, fd.Length)
+		}
+		copy(out[fd.Length-len(str):], str)
+	case 'L':
+		if t, ok := v.(bool); ok && t {
+			out[0] = 'T'
+		} else if ok {
+			out[0] = 'F'
+		} else {
+			out[0] = '?'
+		}
+	case 'D':
+		copy(out, strings.ReplaceAll(propString(v), "-", ""))
+	default:
+		str := propString(v)
+		rs := []rune(str)
+		for len(encode(string(rs))) > fd.Length {
+			rs = rs[:len(rs)-1]
+		}
+		copy(out, encode(string(rs)))
+	}
+	return out
+}
+
+//===============================================================================
+
+var _prjEPSG = regexp.MustCompile(`AUTHORITY\["EPSG",\s*"?(\d+)"?\]\s*\]\s*$`)
+var _prjZone = regexp.MustCompile(`(?i)(3_Degree_)?GK_(Zone_(\d+)|CM_(\d+)E)`)
+var _prjUTM = regexp.MustCompile(`(?i)UTM[_ ]Zone[_ ](\d+)([NS])`)
+
+// SRID 由 .prj 识别EPSG编码: WGS84 CGCS2000 西安80 北京54 Web墨卡托 WGS84/UTM CGCS2000/高斯-克吕格, 无法识别时为0
+func (s *Shapefile) SRID() int {
+	return PrjSRID(s.Prj)
+}
+
+// PrjSRID 由坐标系WKT识别EPSG编码, 无法识别时为0
+func PrjSRID(prj string) int {
+	if m := _prjEPSG.FindStringSubmatch(prj); m != nil {
+		srid, _ := strconv.Atoi(m[1])
+		return srid
+	}
+	up := strings.ToUpper(prj)
+	cgcs := strings.Contains(up, "CGCS") || strings.Contains(up, "CHINA_GEODETIC_COORDINATE_SYSTEM_2000")
+	if strings.HasPrefix(up, "PROJCS") {
+		switch {
+		case strings.Contains(up, "MERCATOR_AUXILIARY_SPHERE") || strings.Contains(up, "PSEUDO-MERCATOR") || strings.Contains(up, "WEB_MERCATOR"):
+			return 3857
+		case strings.Contains(up, "WGS") && _prjUTM.MatchString(prj):
+			m := _prjUTM.FindStringSubmatch(prj)
+			zone, _ := strconv.Atoi(m[1])
+			if strings.EqualFold(m[2], "S") {
+				return 32700 + zone
+			}
+			return 32600 + zone
+		case cgcs && _prjZone.MatchString(prj):
+			m := _prjZone.FindStringSubmatch(prj)
+			three := m[1] != ""
+			if m[3] != "" {
+				zone, _ := strconv.Atoi(m[3])
+				if three {
+					return 4513 + zone - 25
+				}
+				return 4491 + zone - 13
+			}
+			cm, _ := strconv.Atoi(m[4])
+			if three {
+				return 4534 + (cm-75)/3
+			}
+			return 4502 + (cm-75)/6
+		}
+		return 0
+	}
+	switch {
+	case cgcs:
+		return 4490
+	case strings.Contains(up, "XIAN_1980") || strings.Contains(up, "XIAN 1980"):
+		return 4610
+	case strings.Contains(up, "BEIJING_1954") || strings.Contains(up, "BEIJING 1954"):
+		return 4214
+	case strings.Contains(up, "WGS_1984") || strings.Contains(up, "WGS 84") || strings.Contains(up, "WGS84"):
+		return 4326
+	}
+	return 0
+}
+
+// PrjWKT 常用坐标系的 .prj 内容(ESRI WKT): 4326 4490 3857, 其他返回空字符串
+func PrjWKT(srid int) string {
+	switch srid {
+	case 4326:
+		return `GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137.0,298.257223563]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]]`
+	case 4490:
+		return `GEOGCS["GCS_China_Geodetic_Coordinate_System_2000",DATUM["D_China_2000",SPHEROID["CGCS2000",6378137.0,298.257222101]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]]`
+	case 3857:
+		return `PROJCS["WGS_1984_Web_Mercator_Auxiliary_Sphere",GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137.0,298.257223563]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]],PROJECTION["Mercator_Auxiliary_Sphere"],PARAMETER["False_Easting",0.0],PARAMETER["False_Northing",0.0],PARAMETER["Central_Meridian",0.0],PARAMETER["Standard_Parallel_1",0.0],PARAMETER["Auxiliary_Sphere_Type",0.0],UNIT["Meter",1.0]]`
+	}
+	return ""
+}
@@ -0,0 +1,287 @@
+package xutil
+
+import (
+	"container/heap"
+	"math"
+)
+
+/***
+线简化, 经纬度按中心纬度投影为局部平面(米)后计算
+https://en.wikipedia.org/wiki/Ramer%E2%80%93Douglas%E2%80%93Peucker_algorithm  Douglas-Peucker
+https://bost.ocks.org/mike/simplify/                                            Visvalingam-Whyatt
+	点对象不变, 线至少保留2个点, 环至少保留4个点(首尾相同), 退化的内环被去掉
+***/
+
+// localXY 经纬度转局部平面坐标(米), 等距圆柱投影
+func localXY(lat0 float64) func(c []float64) Point {
+	kx := Radians(1) * _earthR * math.Cos(Radians(lat0))
+	ky := Radians(1) * _earthR
+	return func(c []float64) Point {
+		return Point{c[0] * kx, c[1] * ky}
+	}
+}
+
+// geoLocalXY 以几何对象中心纬度建立局部平面投影
+func (g Geo) localXY() func(c []float64) Point {
+	b := g.Box()
+	if b == nil {
+		return localXY(0)
+	}
+	return localXY((b[1] + b[3]) / 2)
+}
+
+// Simplify Douglas-Peucker 简化, tolerance 为最大偏离距离(米)
+func (g Geo) Simplify(tolerance float64) Geo {
+	xy := g.localXY()
+	return g.simplifyWith(func(line [][]float64) [][]float64 {
+		return simplifyDP(line, tolerance, xy)
+	}, false)
+}
+
+// SimplifyVW Visvalingam-Whyatt 简化, tolerance 为有效三角形面积阈值(平方米)
+func (g Geo) SimplifyVW(tolerance float64) Geo {
+	xy := g.localXY()
+	return g.simplifyWith(func(line [][]float64) [][]float64 {
+		return simplifyVW(line, tolerance, xy)
+	}, false)
+}
+
+// SimplifyPreserveTopology Douglas-Peucker 简化, 保证面不自相交、内外环不相交、环不退化
+func (g Geo) SimplifyPreserveTopology(tolerance float64) Geo {
+	xy := g.localXY()
+	return g.simplifyWith(func(line [][]float64) [][]float64 {
+		return simplifyDP(line, tolerance, xy)
+	}, true)
+}
+
+// simplifyWith 对每条线/环应用简化函数
+func (g Geo) simplifyWith(f func(line [][]float64) [][]float64, topology bool) Geo {
+	g1 := Geo{Type: g.Type, Dim: g.Dim, CRS: g.CRS}
+	if g.Geoms != nil {
+		g1.Geoms = make([]Geo, len(g.Geoms))
+		for i, sub := range g.Geoms {
+			g1.Geoms[i] = sub.simplifyWith(f, topology)
+		}
+	}
+	switch g.Type {
+	case "GeometryCollection":
+		return g1
+	case "LineString", "MultiLineString":
+		for _, a := range g.Coords {
+			lines := [][][]float64{}
+			for _, b := range a {
+				lines = append(lines, f(b))
+			}
+			g1.Coords = append(g1.Coords, lines)
+		}
+		return g1.Copy()
+	case "Polygon", "MultiPolygon":
+		for _, rings := range g.Coords {
+			var out [][][]float64
+			if topology {
+				out = simplifyRingsTopology(rings, f)
+			} else {
+				for i, ring := range rings {
+					r := f(ring)
+					if len(r) >= 4 {
+						out = append(out, r)
+					} else if i == 0 {
+						break // 外环退化, 去掉该多边形
+					}
+				}
+			}
+			if len(out) > 0 {
+				g1.Coords = append(g1.Coords, out)
+			}
+		}
+		return g1.Copy()
+	}
+	return g.Copy()
+}
+
+// simplifyRingsTopology 逐环简化, 结果退化或相交时逐步缩小容差重试, 最终保留原环
+func simplifyRingsTopology(rings [][][]float64, f func(line [][]float64) [][]float64) [][][]float64 {
+	out := make([][][]float64, len(rings))
+	for i, ring := range rings {
+		r := f(ring)
+		if len(r) < 4 {
+			r = ring
+		}
+		out[i] = r
+	}
+	for i := range out {
+		if ringsSimple(out, i) {
+			continue
+		}
+		// 在原环的基础上二分补点: 依次保留更多原始点
+		for step := 2; ; step *= 2 {
+			if len(out[i]) >= len(rings[i]) {
+				out[i] = rings[i]
+				break
+			}
+			out[i] = densifyKeep(rings[i], out[i], step)
+			if ringsSimple(out, i) {
+				break
+			}
+		}
+	}
+	return out
+}
+
+// densifyKeep 在简化结果中补回原环中每隔 len/step 个点
+func densifyKeep(orig, simp [][]float64, step int) [][]float64 {
+	keep := make(map[*float64]bool, len(simp))
+	for _, c := range simp {
+		keep[&c[0]] = true
+	}
+	every := len(orig) / step
+	if every < 1 {
+		every = 1
+	}
+	var out [][]float64
+	for i, c := range orig {
+		if keep[&c[0]] || i%every == 0 || i == len(orig)-1 {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// ringsSimple 第i个环是否不自相交且不与其他环相交
+func ringsSimple(rings [][][]float64, i int) bool {
+	ring := rings[i]
+	n := len(ring)
+	for a := 1; a < n; a++ {
+		p1, p2 := coordPoint(ring[a-1]), coordPoint(ring[a])
+		for b := a + 1; b < n; b++ {
+			if b == a+1 || (a == 1 && b == n-1) {
+				continue // 相邻边
+			}
+			if len(segIntersections(p1, p2, coordPoint(ring[b-1]), coordPoint(ring[b]))) > 0 {
+				return false
+			}
+		}
+		for j, other := range rings {
+			if j == i {
+				continue
+			}
+			for b := 1; b < len(other); b++ {
+				if len(segIntersections(p1, p2, coordPoint(other[b-1]), coordPoint(other[b]))) > 0 {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+//===============================================================================
+
+// simplifyDP Douglas-Peucker, 保留原坐标切片(含z,m)
+func simplifyDP(line [][]float64, tolerance float64, xy func(c []float64) Point) [][]float64 {
+	n := len(line)
+	if n <= 2 {
+		return line
+	}
+	pts := make([]Point, n)
+	for i, c := range line {
+		pts[i] = xy(c)
+	}
+	keep := make([]bool, n)
+	keep[0], keep[n-1] = true, true
+	tol2 := tolerance * tolerance
+	stack := [][2]int{{0, n - 1}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		maxd, idx := -1.0, -1
+		for i := s[0] + 1; i < s[1]; i++ {
+			if d, _ := pointSegDist2(pts[i], pts[s[0]], pts[s[1]]); d > maxd {
+				maxd, idx = d, i
+			}
+		}
+		if idx > 0 && maxd > tol2 {
+			keep[idx] = true
+			stack = append(stack, [2]int{s[0], idx}, [2]int{idx, s[1]})
+		}
+	}
+	out := make([][]float64, 0, n)
+	for i, c := range line {
+		if keep[i] {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+type vwPoint struct {
+	i, prev, next int
+	area          float64
+	index         int
+}
+
+type vwHeap []*vwPoint
+
+func (h vwHeap) Len() int            { return len(h) }
+func (h vwHeap) Less(i, j int) bool  { return h[i].area < h[j].area }
+func (h vwHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i]; h[i].index = i; h[j].index = j }
+func (h *vwHeap) Push(x interface{}) { p := x.(*vwPoint); p.index = len(*h); *h = append(*h, p) }
+func (h *vwHeap) Pop() interface{} {
+	old := *h
+	p := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return p
+}
+
+// simplifyVW Visvalingam-Whyatt, 依次去掉有效面积最小的点
+func simplifyVW(line [][]float64, tolerance float64, xy func(c []float64) Point) [][]float64 {
+	n := len(line)
+	if n <= 2 {
+		return line
+	}
+	pts := make([]Point, n)
+	for i, c := range line {
+		pts[i] = xy(c)
+	}
+	area := func(a, b, c int) float64 {
+		return math.Abs(cross(pts[a], pts[b], pts[c])) / 2
+	}
+	nodes := make([]*vwPoint, n)
+	h := &vwHeap{}
+	for i := 0; i < n; i++ {
+		nodes[i] = &vwPoint{i: i, prev: i - 1, next: i + 1, area: math.Inf(1)}
+		if i > 0 && i < n-1 {
+			nodes[i].area = area(i-1, i, i+1)
+			heap.Push(h, nodes[i])
+		}
+	}
+	removed := make([]bool, n)
+	last := 0.0
+	for h.Len() > 0 {
+		p := heap.Pop(h).(*vwPoint)
+		// 有效面积单调不减, 保证先去掉的点面积不大于后去掉的点
+		if p.area < last {
+			p.area = last
+		}
+		last = p.area
+		if p.area >= tolerance {
+			break
+		}
+		removed[p.i] = true
+		prev, next := nodes[p.prev], nodes[p.next]
+		prev.next, next.prev = next.i, prev.i
+		for _, q := range []*vwPoint{prev, next} {
+			if q.prev >= 0 && q.next < n {
+				q.area = area(q.prev, q.i, q.next)
+				heap.Fix(h, q.index)
+			}
+		}
+	}
+	out := make([][]float64, 0, n)
+	for i, c := range line {
+		if !removed[i] {
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,165 @@
+package xutil
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+/***
+瓦片覆盖与瓦片金字塔
+https://learn.microsoft.com/en-us/bingmaps/articles/bing-maps-tile-system  quadkey
+	TileXYZ     Google/OSM/高德, 原点在左上角
+	TileTMS     y 轴翻转, 原点在左下角
+	TileTencent 腾讯, 同 TMS
+	TileBaidu   百度, BD09经纬度转百度墨卡托, 原点为赤道与本初子午线交点, 瓦片边长 256*2^(18-z)
+	覆盖只返回与几何对象相交的瓦片, 不是边界框内的全部瓦片
+***/
+
+const _mercatorMaxLat = 85.05112877980659
+
+// TileScheme 瓦片编号方式
+type TileScheme int
+
+// 瓦片编号方式
+const (
+	TileXYZ TileScheme = iota
+	TileTMS
+	TileTencent
+	TileBaidu
+)
+
+// Tile 瓦片编号
+type Tile struct {
+	X, Y, Z int
+}
+
+// Tile 经纬度所在瓦片
+func (s TileScheme) Tile(lon, lat float64, z int) (x, y int) {
+	if s == TileBaidu {
+		return Bd09ToTile(lon, lat, z)
+	}
+	n := 1<<uint(z) - 1
+	x, y = Wgs2Tile(lon, math.Max(-_mercatorMaxLat, math.Min(_mercatorMaxLat, lat)), z)
+	x, y = clampInt(x, 0, n), clampInt(y, 0, n)
+	if s == TileTMS || s == TileTencent {
+		y = n - y
+	}
+	return
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// box 瓦片边界 minx, miny, maxx, maxy
+func (s TileScheme) box(x, y, z int) []float64 {
+	switch s {
+	case TileBaidu:
+		size := math.Pow(2, float64(18-z)) * 256
+		minx, miny := MercatorToBd09(float64(x)*size, float64(y)*size)
+		maxx, maxy := MercatorToBd09(float64(x+1)*size, float64(y+1)*size)
+		return []float64{minx, miny, maxx, maxy}
+	case TileTMS, TileTencent:
+		y = 1<<uint(z) - 1 - y
+	}
+	maxy, minx := Tile2Wgs(x, y, z)
+	miny, maxx := Tile2Wgs(x+1, y+1, z)
+	return []float64{minx, miny, maxx, maxy}
+}
+
+// Bounds 瓦片边界 Polygon
+func (s TileScheme) Bounds(x, y, z int) Geo {
+	return boxGeo(s.box(x, y, z))
+}
+
+// Cover 与几何对象相交的所有瓦片
+func (s TileScheme) Cover(g Geo, z int) []Tile {
+	b := g.Box()
+	if b == nil {
+		return nil
+	}
+	x0, y0 := s.Tile(b[0], b[1], z)
+	x1, y1 := s.Tile(b[2], b[3], z)
+	if y0 > y1 {
+		y0, y1 = y1, y0
+	}
+	var tiles []Tile
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			if boxGeo(s.box(x, y, z)).Intersects(g) {
+				tiles = append(tiles, Tile{x, y, z})
+			}
+		}
+	}
+	return tiles
+}
+
+// Pyramid minZ 到 maxZ 各级与几何对象相交的瓦片
+func (s TileScheme) Pyramid(g Geo, minZ, maxZ int) []Tile {
+	var tiles []Tile
+	for z := minZ; z <= maxZ; z++ {
+		tiles = append(tiles, s.Cover(g, z)...)
+	}
+	return tiles
+}
+
+// TileCover 与几何对象相交的所有XYZ瓦片
+func TileCover(g Geo, z int) []Tile {
+	return TileXYZ.Cover(g, z)
+}
+
+// TilePyramid minZ 到 maxZ 各级与几何对象相交的XYZ瓦片
+func TilePyramid(g Geo, minZ, maxZ int) []Tile {
+	return TileXYZ.Pyramid(g, minZ, maxZ)
+}
+
+// TileBounds XYZ瓦片边界 Polygon
+func TileBounds(x, y, z int) Geo {
+	return TileXYZ.Bounds(x, y, z)
+}
+
+//===============================================================================
+
+// TileQuadkey XYZ瓦片编号转quadkey
+func TileQuadkey(x, y, z int) string {
+	var sb strings.Builder
+	for i := z; i > 0; i-- {
+		d, mask := byte('0'), 1<<uint(i-1)
+		if x&mask != 0 {
+			d++
+		}
+		if y&mask != 0 {
+			d += 2
+		}
+		sb.WriteByte(d)
+	}
+	return sb.String()
+}
+
+// QuadkeyTile quadkey转XYZ瓦片编号
+func QuadkeyTile(quadkey string) (x, y, z int, err error) {
+	z = len(quadkey)
+	for i, c := range quadkey {
+		mask := 1 << uint(z-i-1)
+		switch c {
+		case '0':
+		case '1':
+			x |= mask
+		case '2':
+			y |= mask
+		case '3':
+			x |= mask
+			y |= mask
+		default:
+			return 0, 0, 0, fmt.Errorf("quadkey字符错误: %s %q", quadkey, c)
+		}
+	}
+	return
+}
@@ -0,0 +1,324 @@
+package xutil
+
+import (
+	"math"
+	"sort"
+)
+
+/***
+有效性检查与修复(平面), 规则同 OGC Simple Features / GEOS
+	面: 环闭合且至少4个点, 环不自相交, 内外环最多在单点接触且不分割内部, 内环在外环内且互不嵌套
+	多面: 各面只在单点接触, 外环互不嵌套
+	MakeValid: 去掉无效坐标和连续重复点, 闭合环, 外环逆时针、内环顺时针(RFC 7946),
+	自相交的面按奇偶规则拆分后再合并
+***/
+
+// 无效原因
+const (
+	_validOK            = "Valid Geometry"
+	_invalidCoordinate  = "Invalid Coordinate"
+	_invalidTooFew      = "Too few distinct points in geometry component"
+	_invalidNotClosed   = "Ring is not closed"
+	_invalidSelfInt     = "Self-intersection"
+	_invalidRingSelfInt = "Ring Self-intersection"
+	_invalidHoleOutside = "Hole lies outside shell"
+	_invalidNestedHoles = "Holes are nested"
+	_invalidDisconnect  = "Interior is disconnected"
+	_invalidNestedShell = "Nested shells"
+)
+
+// IsValid 是否为有效的几何对象, 无效时返回原因及位置, 有效时 reason 为 "Valid Geometry"
+func (g Geo) IsValid() (valid bool, reason string, loc Point) {
+	if reason, loc = g.invalidReason(); reason != "" {
+		return false, reason, loc
+	}
+	return true, _validOK, loc
+}
+
+func (g Geo) invalidReason() (reason string, loc Point) {
+	for _, sub := range g.Geoms {
+		if reason, loc = sub.invalidReason(); reason != "" {
+			return
+		}
+	}
+	g.eachCoord(func(c []float64) {
+		if reason == "" && !validCoord(c) {
+			reason, loc = _invalidCoordinate, coordPoint(c)
+		}
+	})
+	if reason != "" {
+		return
+	}
+	switch g.Type {
+	case "LineString", "MultiLineString":
+		g.eachPart(nil, func(b [][]float64) {
+			if reason == "" && len(dropRepeated(b)) < 2 {
+				reason, loc = _invalidTooFew, coordPoint(b[0])
+			}
+		}, nil)
+	case "Polygon", "MultiPolygon":
+		return polysInvalid(g.polys())
+	}
+	return
+}
+
+// validCoord 坐标是否为有限值
+func validCoord(c []float64) bool {
+	for _, v := range c {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// dropRepeated 去掉连续重复的点
+func dropRepeated(b [][]float64) [][]float64 {
+	out := make([][]float64, 0, len(b))
+	for _, c := range b {
+		if len(out) == 0 || !pointEqual(coordPoint(out[len(out)-1]), coordPoint(c)) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// vEdge 检查用的边, 记录所属的面、环及序号
+type vEdge struct {
+	a, b             Point
+	poly, ring, i, n int
+}
+
+// polysInvalid 多边形的无效原因
+func polysInvalid(polys [][][][]float64) (string, Point) {
+	var edges []vEdge
+	for pi, rings := range polys {
+		for ri, ring := range rings {
+			if len(ring) == 0 {
+				continue
+			}
+			if !pointEqual(coordPoint(ring[0]), coordPoint(ring[len(ring)-1])) {
+				return _invalidNotClosed, coordPoint(ring[0])
+			}
+			ring = dropRepeated(ring)
+			if len(ring) < 4 {
+				return _invalidTooFew, coordPoint(ring[0])
+			}
+			for i := 1; i < len(ring); i++ {
+				edges = append(edges, vEdge{coordPoint(ring[i-1]), coordPoint(ring[i]), pi, ri, i - 1, len(ring) - 1})
+			}
+		}
+	}
+
+	// 边两两求交, 记录不同环之间的接触点
+	type touch struct {
+		poly, r1, r2 int
+		p            Point
+	}
+	var touches []touch
+	seen := map[touch]bool{}
+	sort.Slice(edges, func(i, j int) bool {
+		return math.Min(edges[i].a.X, edges[i].b.X) < math.Min(edges[j].a.X, edges[j].b.X)
+	})
+	for n, e := range edges {
+		maxx := math.Max(e.a.X, e.b.X) + _geoEps
+		for _, f := range edges[n+1:] {
+			if math.Min(f.a.X, f.b.X) > maxx {
+				break
+			}
+			ps := segIntersections(e.a, e.b, f.a, f.b)
+			if len(ps) == 0 {
+				continue
+			}
+			overlap := false
+			for _, p := range ps[1:] {
+				overlap = overlap || !pointEqual(p, ps[0])
+			}
+			if overlap {
+				return _invalidSelfInt, ps[0]
+			}
+			p := ps[0]
+			vertex := (pointEqual(p, e.a) || pointEqual(p, e.b)) && (pointEqual(p, f.a) || pointEqual(p, f.b))
+			end := pointEqual(p, e.a) || pointEqual(p, e.b) || pointEqual(p, f.a) || pointEqual(p, f.b)
+			switch {
+			case !end:
+				return _invalidSelfInt, p
+			case e.poly != f.poly:
+				// 不同的面允许在单点接触
+			case e.ring == f.ring:
+				d := e.i - f.i
+				adjacent := d == 1 || d == -1 || d == e.n-1 || d == 1-e.n
+				if !adjacent || !vertex {
+					return _invalidRingSelfInt, p
+				}
+			default:
+				r1, r2 := e.ring, f.ring
+				if r1 > r2 {
+					r1, r2 = r2, r1
+				}
+				t := touch{e.poly, r1, r2, p}
+				for k := range seen {
+					if k.poly == t.poly && k.r1 == r1 && k.r2 == r2 && pointEqual(k.p, p) {
+						t = k
+					}
+				}
+				if !seen[t] {
+					seen[t] = true
+					touches = append(touches, t)
+				}
+			}
+		}
+	}
+
+	for _, rings := range polys {
+		if len(rings) == 0 {
+			continue
+		}
+		for i, hole := range rings[1:] {
+			p, ok := ringInnerPoint(hole, rings[:1])
+			if ok && locateRings(rings[:1], p) == locExterior {
+				return _invalidHoleOutside, p
+			}
+			for j, other := range rings[1:] {
+				if j != i && ok && locateRings([][][]float64{other}, p) == locInterior {
+					return _invalidNestedHoles, p
+				}
+			}
+		}
+	}
+
+	// 环之间的接触构成回路时内部被分割
+	parent := map[[2]int]([2]int){}
+	var find func(k [2]int) [2]int
+	find = func(k [2]int) [2]int {
+		if p, ok := parent[k]; ok && p != k {
+			r := find(p)
+			parent[k] = r
+			return r
+		}
+		return k
+	}
+	for _, t := range touches {
+		a, b := find([2]int{t.poly, t.r1}), find([2]int{t.poly, t.r2})
+		if a == b {
+			return _invalidDisconnect, t.p
+		}
+		parent[a] = b
+	}
+
+	for i, rings := range polys {
+		for j, other := range polys {
+			if i == j || len(other) == 0 || len(rings) == 0 {
+				continue
+			}
+			if p, ok := ringInnerPoint(other[0], rings); ok && locateRings(rings, p) == locInterior {
+				return _invalidNestedShell, p
+			}
+		}
+	}
+	return "", Point{}
+}
+
+// ringInnerPoint 环上不在rings边界上的一个点(顶点或边的中点)
+func ringInnerPoint(ring [][]float64, rings [][][]float64) (Point, bool) {
+	for i, c := range ring {
+		p := coordPoint(c)
+		if locateRings(rings, p) != locBoundary {
+			return p, true
+		}
+		if i > 0 {
+			m := Point{(ring[i-1][0] + c[0]) / 2, (ring[i-1][1] + c[1]) / 2}
+			if locateRings(rings, m) != locBoundary {
+				return m, true
+			}
+		}
+	}
+	return Point{}, false
+}
+
+//===============================================================================
+
+// MakeValid 修复为有效的几何对象, 保留坐标系
+func (g Geo) MakeValid() Geo {
+	g1 := Geo{Type: g.Type, Dim: g.Dim, CRS: g.CRS}
+	for _, sub := range g.Geoms {
+		g1.Geoms = append(g1.Geoms, sub.MakeValid())
+	}
+	switch g.Type {
+	case "Point", "MultiPoint":
+		var cs [][]float64
+		g.eachPart(func(c []float64) {
+			if validCoord(c) {
+				cs = append(cs, c)
+			}
+		}, nil, nil)
+		if len(cs) > 0 {
+			g1.Coords = [][][][]float64{{cs}}
+		}
+	case "LineString", "MultiLineString":
+		var lines [][][]float64
+		g.eachPart(nil, func(b [][]float64) {
+			if line := dropRepeated(validCoords(b)); len(line) >= 2 {
+				lines = append(lines, line)
+			}
+		}, nil)
+		if len(lines) > 0 {
+			g1.Coords = [][][][]float64{lines}
+		}
+	case "Polygon", "MultiPolygon":
+		var polys [][][][]float64
+		for _, rings := range g.Coords {
+			var out [][][]float64
+			for i, ring := range rings {
+				ring = dropRepeated(validCoords(ring))
+				if len(ring) > 0 && !pointEqual(coordPoint(ring[0]), coordPoint(ring[len(ring)-1])) {
+					ring = append(ring, ring[0])
+				}
+				if len(ring) >= 4 {
+					out = append(out, ring)
+				} else if i == 0 {
+					break
+				}
+			}
+			if len(out) > 0 {
+				polys = append(polys, out)
+			}
+		}
+		if r, _ := polysInvalid(polys); r == "" {
+			for _, rings := range polys {
+				for i, ring := range rings {
+					if (ringSignedArea(ring) > 0) != (i == 0) {
+						for a, b := 0, len(ring)-1; a < b; a, b = a+1, b-1 {
+							ring[a], ring[b] = ring[b], ring[a]
+						}
+					}
+				}
+			}
+			g1.Coords = polys
+		} else {
+			parts := make([][][][][]float64, len(polys))
+			for i, rings := range polys {
+				parts[i] = overlay([][][][]float64{rings}, nil, ovParity)
+			}
+			g1.Dim = ""
+			g1.Coords = unionPolys(parts)
+		}
+		if len(g1.Coords) > 1 {
+			g1.Type = "MultiPolygon"
+		}
+	default:
+		return g1
+	}
+	return g1.Copy()
+}
+
+// validCoords 去掉无效坐标
+func validCoords(b [][]float64) [][]float64 {
+	out := make([][]float64, 0, len(b))
+	for _, c := range b {
+		if validCoord(c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,463 @@
+package xutil
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+/***
+WKB / EWKB
+https://libgeos.org/specifications/wkb/
+https://postgis.net/docs/using_postgis_dbmanagement.html#EWKB_EWKT
+	byteOrder uint8   0:XDR(big endian) 1:NDR(little endian)
+	wkbType   uint32  1:Point 2:LineString 3:Polygon 4:MultiPoint 5:MultiLineString 6:MultiPolygon 7:GeometryCollection
+	ISO  Z/M/ZM 类型为 1000+/2000+/3000+ wkbType
+	EWKB 在 wkbType 高位增加标志: 0x20000000 SRID 0x80000000 Z 0x40000000 M
+	空点以 NaN NaN 表示, 其他空对象数量为0
+***/
+
+const (
+	_wkbXDR = 0
+	_wkbNDR = 1
+
+	_ewkbSRID = 0x20000000
+	_ewkbZ    = 0x80000000
+	_ewkbM    = 0x40000000
+)
+
+var _wkbTypes = map[string]uint32{"Point": 1, "LineString": 2, "Polygon": 3, "MultiPoint": 4, "MultiLineString": 5, "MultiPolygon": 6, "GeometryCollection": 7}
+
+// FromWKB 解析WKB为Geo(兼容EWKB,忽略SRID)
+func FromWKB(wkb []byte) (g Geo, err error) {
+	g, _, err = FromEWKB(wkb)
+	return
+}
+
+// FromEWKB 解析EWKB为Geo, 返回SRID(无SRID时为0)
+func FromEWKB(ewkb []byte) (g Geo, srid int, err error) {
+	w := &wkbReader{b: ewkb}
+	g, srid, err = readWKB(w)
+	if err != nil {
+		return g, srid, err
+	}
+	if w.off < len(w.b) {
+		return g, srid, fmt.Errorf("WKB解析失败: 剩余%d字节", len(w.b)-w.off)
+	}
+	return
+}
+
+// FromWKBHex 解析十六进制WKB/EWKB为Geo
+func FromWKBHex(s string) (g Geo, err error) {
+	b, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return g, err
+	}
+	return FromWKB(b)
+}
+
+// FromEWKBHex 解析十六进制EWKB为Geo, 返回SRID
+func FromEWKBHex(s string) (g Geo, srid int, err error) {
+	b, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return g, 0, err
+	}
+	return FromEWKB(b)
+}
+
+// WKB 生成WKB(little endian)
+func (g Geo) WKB() ([]byte, error) {
+	return g.WKBOrder(binary.LittleEndian)
+}
+
+// WKBOrder 生成指定字节序的WKB
+func (g Geo) WKBOrder(order binary.ByteOrder) ([]byte, error) {
+	w := &wkbWriter{order: order}
+	err := writeWKB(w, g, 0, false)
+	return w.b, err
+}
+
+// EWKB 生成带SRID的EWKB(little endian)
+func (g Geo) EWKB(srid int) ([]byte, error) {
+	return g.EWKBOrder(srid, binary.LittleEndian)
+}
+
+// EWKBOrder 生成指定字节序的EWKB
+func (g Geo) EWKBOrder(srid int, order binary.ByteOrder) ([]byte, error) {
+	w := &wkbWriter{order: order, ewkb: true}
+	err := writeWKB(w, g, srid, true)
+	return w.b, err
+}
+
+// WKBHex 生成十六进制WKB(大写,与PostGIS一致)
+func (g Geo) WKBHex() (string, error) {
+	b, err := g.WKB()
+	return strings.ToUpper(hex.EncodeToString(b)), err
+}
+
+// EWKBHex 生成十六进制EWKB
+func (g Geo) EWKBHex(srid int) (string, error) {
+	b, err := g.EWKB(srid)
+	return strings.ToUpper(hex.EncodeToString(b)), err
+}
+
+//===============================================================================
+
+type wkbReader struct {
+	b      []byte
+	off    int
+	order  binary.ByteOrder
+	stride int
+}
+
+func (w *wkbReader) read(n int) ([]byte, error) {
+	if w.off+n > len(w.b) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := w.b[w.off : w.off+n]
+	w.off += n
+	return b, nil
+}
+
+func (w *wkbReader) uint32() (uint32, error) {
+	b, err := w.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return w.order.Uint32(b), nil
+}
+
+func (w *wkbReader) point() ([]float64, error) {
+	b, err := w.read(8 * w.stride)
+	if err != nil {
+		return nil, err
+	}
+	c := make([]float64, w.stride)
+	for i := range c {
+		c[i] = math.Float64frombits(w.order.Uint64(b[8*i:]))
+	}
+	return c, nil
+}
+
+func (w *wkbReader) points() ([][]float64, error) {
+	n, err := w.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if int(n) > (len(w.b)-w.off)/(8*w.stride) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	points := make([][]float64, 0, n)
+	for i := uint32(0); i < n; i++ {
+		p, err := w.point()
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+func (w *wkbReader) rings() ([][][]float64, error) {
+	n, err := w.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if int(n) > (len(w.b)-w.off)/4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	rings := make([][][]float64, 0, n)
+	for i := uint32(0); i < n; i++ {
+		ring, err := w.points()
+		if err != nil {
+			return nil, err
+		}
+		rings = append(rings, ring)
+	}
+	return rings, nil
+}
+
+// readWKB 读取一个WKB/EWKB几何对象, 每个子对象自带字节序
+func readWKB(w *wkbReader) (g Geo, srid int, err error) {
+	bo, err := w.read(1)
+	if err != nil {
+		return
+	}
+	switch bo[0] {
+	case _wkbXDR:
+		w.order = binary.BigEndian
+	case _wkbNDR:
+		w.order = binary.LittleEndian
+	default:
+		return g, 0, fmt.Errorf("WKB字节序错误: %d", bo[0])
+	}
+
+	t, err := w.uint32()
+	if err != nil {
+		return
+	}
+	if t&_ewkbSRID != 0 {
+		s, err := w.uint32()
+		if err != nil {
+			return g, 0, err
+		}
+		srid = int(int32(s))
+	}
+	base := t & 0x0fffffff
+	iso := base / 1000
+	base %= 1000
+	hasZ := t&_ewkbZ != 0 || iso == 1 || iso == 3
+	hasM := t&_ewkbM != 0 || iso == 2 || iso == 3
+	if iso > 3 {
+		return g, srid, fmt.Errorf("不支持的WKB类型: %d", t)
+	}
+	dim := ""
+	switch {
+	case hasZ && hasM:
+		dim = "XYZM"
+	case hasZ:
+		dim = "XYZ"
+	case hasM:
+		dim = "XYM"
+	}
+	g.Dim = dim
+	w.stride = g.Stride()
+
+	switch base {
+	case 1:
+		g.Type = "Point"
+		var p []float64
+		if p, err = w.point(); err == nil && !math.IsNaN(p[0]) {
+			g.Coords = [][][][]float64{{{p}}}
+		}
+	case 2:
+		g.Type = "LineString"
+		var ps [][]float64
+		if ps, err = w.points(); err == nil && len(ps) > 0 {
+			g.Coords = [][][][]float64{{ps}}
+		}
+	case 3:
+		g.Type = "Polygon"
+		var rings [][][]float64
+		if rings, err = w.rings(); err == nil && len(rings) > 0 {
+			g.Coords = [][][][]float64{rings}
+		}
+	case 4, 5, 6:
+		g, err = readWKBMulti(w, base)
+	case 7:
+		g, err = readWKBCollection(w)
+	default:
+		err = fmt.Errorf("不支持的WKB类型: %d", t)
+	}
+	g.Dim = dim
+	return g, srid, err
+}
+
+// readWKBMulti 读取 MultiPoint/MultiLineString/MultiPolygon
+func readWKBMulti(w *wkbReader, t uint32) (g Geo, err error) {
+	n, err := w.uint32()
+	if err != nil {
+		return
+	}
+	if int(n) > (len(w.b)-w.off)/5 {
+		return g, io.ErrUnexpectedEOF
+	}
+	g.Type = map[uint32]string{4: "MultiPoint", 5: "MultiLineString", 6: "MultiPolygon"}[t]
+	var points [][]float64
+	var lines [][][]float64
+	var polygons [][][][]float64
+	for i := uint32(0); i < n; i++ {
+		sub, _, err := readWKB(w)
+		if err != nil {
+			return g, err
+		}
+		if _wkbTypes[sub.Type] != t-3 {
+			return g, fmt.Errorf("%s 中包含 %s", g.Type, sub.Type)
+		}
+		empty := sub.IsEmpty()
+		switch {
+		case t == 4 && empty:
+			points = append(points, []float64{})
+		case t == 4:
+			points = append(points, sub.Coords[0][0][0])
+		case t == 5 && empty:
+			lines = append(lines, [][]float64{})
+		case t == 5:
+			lines = append(lines, sub.Coords[0][0])
+		case t == 6 && empty:
+			polygons = append(polygons, [][][]float64{})
+		case t == 6:
+			polygons = append(polygons, sub.Coords[0])
+		}
+	}
+	if n == 0 {
+		return
+	}
+	switch t {
+	case 4:
+		g.Coords = [][][][]float64{{points}}
+	case 5:
+		g.Coords = [][][][]float64{lines}
+	case 6:
+		g.Coords = polygons
+	}
+	return
+}
+
+// readWKBCollection 读取 GeometryCollection
+func readWKBCollection(w *wkbReader) (g Geo, err error) {
+	n, err := w.uint32()
+	if err != nil {
+		return
+	}
+	if int(n) > (len(w.b)-w.off)/5 {
+		return g, io.ErrUnexpectedEOF
+	}
+	g.Type = "GeometryCollection"
+	g.Geoms = make([]Geo, 0, n)
+	for i := uint32(0); i < n; i++ {
+		sub, _, err := readWKB(w)
+		if err != nil {
+			return g, err
+		}
+		g.Geoms = append(g.Geoms, sub)
+	}
+	return
+}
+
+//===============================================================================
+
+type wkbWriter struct {
+	b      []byte
+	order  binary.ByteOrder
+	ewkb   bool
+	stride int
+}
+
+func (w *wkbWriter) uint32(v uint32) {
+	var b [4]byte
+	w.order.PutUint32(b[:], v)
+	w.b = append(w.b, b[:]...)
+}
+
+func (w *wkbWriter) float64(v float64) {
+	var b [8]byte
+	w.order.PutUint64(b[:], math.Float64bits(v))
+	w.b = append(w.b, b[:]...)
+}
+
+// header 写入字节序与类型, WKB使用ISO维度编码, EWKB使用高位标志
+func (w *wkbWriter) header(t uint32, g Geo, srid int, withSRID bool) {
+	if w.order == binary.BigEndian {
+		w.b = append(w.b, _wkbXDR)
+	} else {
+		w.b = append(w.b, _wkbNDR)
+	}
+	w.stride = g.Stride()
+	if !w.ewkb {
+		if g.HasZ() {
+			t += 1000
+		}
+		if g.HasM() {
+			t += 2000
+		}
+		w.uint32(t)
+		return
+	}
+	if g.HasZ() {
+		t |= _ewkbZ
+	}
+	if g.HasM() {
+		t |= _ewkbM
+	}
+	if withSRID {
+		w.uint32(t | _ewkbSRID)
+		w.uint32(uint32(srid))
+		return
+	}
+	w.uint32(t)
+}
+
+// point 写入一个坐标, 空点或缺少的维度以NaN填充
+func (w *wkbWriter) point(c []float64) {
+	if len(c) < 2 {
+		c = nil
+	}
+	for i := 0; i < w.stride; i++ {
+		if i < len(c) {
+			w.float64(c[i])
+		} else {
+			w.float64(math.NaN())
+		}
+	}
+}
+
+func (w *wkbWriter) points(ps [][]float64) {
+	w.uint32(uint32(len(ps)))
+	for _, c := range ps {
+		w.point(c)
+	}
+}
+
+func (w *wkbWriter) rings(rings [][][]float64) {
+	w.uint32(uint32(len(rings)))
+	for _, ring := range rings {
+		w.points(ring)
+	}
+}
+
+// writeWKB 写入WKB/EWKB, 子对象不再携带SRID
+func writeWKB(w *wkbWriter, g Geo, srid int, withSRID bool) error {
+	t, ok := _wkbTypes[g.Type]
+	if !ok {
+		return fmt.Errorf("不支持的WKB类型: %s", g.Type)
+	}
+	w.header(t, g, srid, withSRID)
+	if g.Type == "GeometryCollection" {
+		w.uint32(uint32(len(g.Geoms)))
+		for _, sub := range g.Geoms {
+			if err := writeWKB(w, sub, 0, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if g.IsEmpty() {
+		if g.Type == "Point" {
+			w.point(nil)
+		} else {
+			w.uint32(0)
+		}
+		return nil
+	}
+	switch g.Type {
+	case "Point":
+		w.point(g.Coords[0][0][0])
+	case "LineString":
+		w.points(g.Coords[0][0])
+	case "Polygon":
+		w.rings(g.Coords[0])
+	case "MultiPoint":
+		w.uint32(uint32(len(g.Coords[0][0])))
+		for _, c := range g.Coords[0][0] {
+			w.header(1, g, 0, false)
+			w.point(c)
+		}
+	case "MultiLineString":
+		w.uint32(uint32(len(g.Coords[0])))
+		for _, line := range g.Coords[0] {
+			w.header(2, g, 0, false)
+			w.points(line)
+		}
+	case "MultiPolygon":
+		w.uint32(uint32(len(g.Coords)))
+		for _, polygon := range g.Coords {
+			w.header(3, g, 0, false)
+			w.rings(polygon)
+		}
+	}
+	return nil
+}